@@ -0,0 +1,39 @@
+//go:build go || all
+// +build go all
+
+package examples
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/testing/integration"
+)
+
+// getGoBaseOptions resolves the modules of the example from the module cache
+// seeded by `make examples_deps`, used as a file proxy.
+func getGoBaseOptions(t *testing.T) integration.ProgramTestOptions {
+	base := getBaseOptions(t)
+	proxy := filepath.Join(getDepsDir(t), "go", "cache", "download")
+	baseGo := base.With(integration.ProgramTestOptions{
+		Dependencies: []string{
+			filepath.Join(getCwd(t), "..", "sdk"),
+		},
+		Env: []string{
+			fmt.Sprintf("GOPROXY=file://%s", filepath.ToSlash(proxy)),
+			"GOSUMDB=off",
+			"GOTOOLCHAIN=local",
+		},
+	})
+
+	return baseGo
+}
+
+func TestGoExample(t *testing.T) {
+	test := getGoBaseOptions(t).With(integration.ProgramTestOptions{
+		Dir: filepath.Join(getCwd(t), "go"),
+	})
+
+	integration.ProgramTest(t, &test)
+}
@@ -4,19 +4,54 @@
 package examples
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
 	"github.com/pulumi/pulumi/pkg/v3/testing/integration"
 )
 
+// getJSBaseOptions installs the packages of the example from the yarn cache
+// seeded by `make examples_deps`. Yarn only installs from its cache with the
+// --offline flag, which the test harness has no option for, so yarn is run
+// through a wrapper adding it.
 func getJSBaseOptions(t *testing.T) integration.ProgramTestOptions {
-	base := getBaseOptions()
+	base := getBaseOptions(t)
 	baseJS := base.With(integration.ProgramTestOptions{
 		Dependencies: []string{
-			"@pulumi/foo",
+			"@pulumi/netbox",
+		},
+		YarnBin: offlineYarn(t),
+		Env: []string{
+			"YARN_CACHE_FOLDER=" + filepath.Join(getDepsDir(t), "yarn"),
 		},
 	})
 
 	return baseJS
 }
+
+func TestNodeExample(t *testing.T) {
+	test := getJSBaseOptions(t).With(integration.ProgramTestOptions{
+		Dir: filepath.Join(getCwd(t), "nodejs"),
+	})
+
+	integration.ProgramTest(t, &test)
+}
+
+// offlineYarn writes a script running yarn with --offline and returns its path.
+func offlineYarn(t *testing.T) string {
+	yarn, err := exec.LookPath("yarn")
+	if err != nil {
+		t.Fatalf("locating yarn: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "yarn")
+	script := fmt.Sprintf("#!/bin/sh\nexec %q --offline \"$@\"\n", yarn)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing yarn wrapper: %v", err)
+	}
+
+	return path
+}
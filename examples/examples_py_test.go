@@ -10,13 +10,27 @@ import (
 	"github.com/pulumi/pulumi/pkg/v3/testing/integration"
 )
 
+// getPythonBaseOptions installs the packages of the example from the wheels
+// downloaded by `make examples_deps`, without querying an index.
 func getPythonBaseOptions(t *testing.T) integration.ProgramTestOptions {
-	base := getBaseOptions()
+	base := getBaseOptions(t)
 	basePython := base.With(integration.ProgramTestOptions{
 		Dependencies: []string{
 			filepath.Join("..", "sdk", "python", "bin"),
 		},
+		Env: []string{
+			"PIP_NO_INDEX=1",
+			"PIP_FIND_LINKS=" + filepath.Join(getDepsDir(t), "pip"),
+		},
 	})
 
 	return basePython
 }
+
+func TestPythonExample(t *testing.T) {
+	test := getPythonBaseOptions(t).With(integration.ProgramTestOptions{
+		Dir: filepath.Join(getCwd(t), "python"),
+	})
+
+	integration.ProgramTest(t, &test)
+}
@@ -2,11 +2,16 @@ package examples
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
 	"github.com/pulumi/pulumi/pkg/v3/testing/integration"
 )
 
+// testToken is the API token the examples use against the fake NetBox.
+const testToken = "0123456789abcdef0123456789abcdef01234567"
+
 func getCwd(t *testing.T) string {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -16,9 +21,74 @@ func getCwd(t *testing.T) string {
 	return cwd
 }
 
-func getBaseOptions() integration.ProgramTestOptions {
+// getDepsDir returns the directory holding the dependencies of the examples,
+// as downloaded by `make examples_deps`, so they can be installed offline.
+func getDepsDir(t *testing.T) string {
+	dir := filepath.Join(getCwd(t), ".deps")
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("example dependencies not found in %s, run `make examples_deps` first", dir)
+	}
+
+	return dir
+}
+
+// getBaseOptions starts a fake NetBox API for the test and points the example
+// at it, using a local file backend so no network access or Pulumi Cloud
+// account is needed. The provider binary is taken from the repository's bin
+// directory, as built by `make provider`, and each language adds the offline
+// settings of its package manager.
+func getBaseOptions(t *testing.T) integration.ProgramTestOptions {
+	api := fakenetbox.NewServer()
+	api.Token = testToken
+	t.Cleanup(api.Close)
+
 	return integration.ProgramTestOptions{
-		RunUpdateTest:        false,
-		ExpectRefreshChanges: true,
+		RunUpdateTest: false,
+		CloudURL:      integration.MakeTempBackend(t),
+		Env: []string{
+			"PULUMI_CONFIG_PASSPHRASE=examples",
+			"PULUMI_SKIP_UPDATE_CHECK=true",
+		},
+		LocalProviders: []integration.LocalDependency{{
+			Package: "netbox",
+			Path:    filepath.Join(getCwd(t), "..", "bin"),
+		}},
+		Config: map[string]string{
+			"netbox:serverUrl": api.URL,
+		},
+		Secrets: map[string]string{
+			"netbox:apiToken": testToken,
+		},
+		ExtraRuntimeValidation: func(t *testing.T, stack integration.RuntimeValidationStackInfo) {
+			validateExample(t, api, stack)
+		},
+	}
+}
+
+// validateExample checks that every example created the same objects in
+// NetBox and exported them as stack outputs.
+func validateExample(t *testing.T, api *fakenetbox.Server, stack integration.RuntimeValidationStackInfo) {
+	for endpoint, want := range map[string]int{
+		"dcim/sites":         1,
+		"dcim/devices":       1,
+		"dcim/interfaces":    1,
+		"ipam/prefixes":      1,
+		"ipam/ip-addresses":  1,
+		"dcim/device-types":  1,
+		"dcim/manufacturers": 1,
+	} {
+		if got := len(api.List(endpoint)); got != want {
+			t.Errorf("%s: found %d objects, want %d", endpoint, got, want)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"prefixCidr": "10.10.0.0/24",
+		"deviceName": "example-device",
+		"ipAddress":  "10.10.0.10/24",
+	} {
+		if got := stack.Outputs[name]; got != want {
+			t.Errorf("output %s = %v, want %s", name, got, want)
+		}
 	}
 }
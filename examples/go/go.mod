@@ -0,0 +1,8 @@
+module github.com/SpikeeLabs/pulumi-netbox/examples/go
+
+go 1.22
+
+require (
+	github.com/SpikeeLabs/pulumi-netbox/sdk v0.0.0
+	github.com/pulumi/pulumi/sdk/v3 v3.108.1
+)
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/SpikeeLabs/pulumi-netbox/sdk/go/netbox"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ref converts a resource ID into the integer reference NetBox expects.
+func ref(r pulumi.CustomResource) pulumi.IntOutput {
+	return r.ID().ApplyT(func(id pulumi.ID) (int, error) {
+		return strconv.Atoi(string(id))
+	}).(pulumi.IntOutput)
+}
+
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		site, err := netbox.NewSite(ctx, "site", &netbox.SiteArgs{
+			Name:   pulumi.String("example-site"),
+			Slug:   pulumi.String("example-site"),
+			Status: pulumi.String("active"),
+		})
+		if err != nil {
+			return err
+		}
+
+		prefix, err := netbox.NewPrefix(ctx, "prefix", &netbox.PrefixArgs{
+			Prefix:      pulumi.String("10.10.0.0/24"),
+			Status:      pulumi.String("active"),
+			SiteId:      ref(site),
+			Description: pulumi.String("example servers"),
+		})
+		if err != nil {
+			return err
+		}
+
+		manufacturer, err := netbox.NewManufacturer(ctx, "manufacturer", &netbox.ManufacturerArgs{
+			Name: pulumi.String("example-manufacturer"),
+		})
+		if err != nil {
+			return err
+		}
+		deviceType, err := netbox.NewDeviceType(ctx, "device-type", &netbox.DeviceTypeArgs{
+			Model:          pulumi.String("example-model"),
+			ManufacturerId: ref(manufacturer),
+		})
+		if err != nil {
+			return err
+		}
+		role, err := netbox.NewDeviceRole(ctx, "role", &netbox.DeviceRoleArgs{
+			Name:     pulumi.String("example-server"),
+			ColorHex: pulumi.String("00ff00"),
+		})
+		if err != nil {
+			return err
+		}
+
+		device, err := netbox.NewDevice(ctx, "device", &netbox.DeviceArgs{
+			Name:         pulumi.String("example-device"),
+			DeviceTypeId: ref(deviceType),
+			RoleId:       ref(role),
+			SiteId:       ref(site),
+		})
+		if err != nil {
+			return err
+		}
+		iface, err := netbox.NewDeviceInterface(ctx, "eth0", &netbox.DeviceInterfaceArgs{
+			Name:     pulumi.String("eth0"),
+			Type:     pulumi.String("1000base-t"),
+			DeviceId: ref(device),
+		})
+		if err != nil {
+			return err
+		}
+
+		ip, err := netbox.NewIpAddress(ctx, "ip", &netbox.IpAddressArgs{
+			IpAddress:         pulumi.String("10.10.0.10/24"),
+			Status:            pulumi.String("active"),
+			DeviceInterfaceId: ref(iface),
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("siteId", site.ID())
+		ctx.Export("prefixCidr", prefix.Prefix)
+		ctx.Export("deviceName", device.Name)
+		ctx.Export("ipAddress", ip.IpAddress)
+		return nil
+	})
+}
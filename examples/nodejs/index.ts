@@ -0,0 +1,53 @@
+import * as pulumi from "@pulumi/pulumi";
+import * as netbox from "@pulumi/netbox";
+
+// NetBox references other objects by their integer ID.
+const ref = (r: pulumi.CustomResource) => r.id.apply(Number);
+
+const site = new netbox.Site("site", {
+    name: "example-site",
+    slug: "example-site",
+    status: "active",
+});
+
+const prefix = new netbox.Prefix("prefix", {
+    prefix: "10.10.0.0/24",
+    status: "active",
+    siteId: ref(site),
+    description: "example servers",
+});
+
+const manufacturer = new netbox.Manufacturer("manufacturer", {
+    name: "example-manufacturer",
+});
+const deviceType = new netbox.DeviceType("device-type", {
+    model: "example-model",
+    manufacturerId: ref(manufacturer),
+});
+const role = new netbox.DeviceRole("role", {
+    name: "example-server",
+    colorHex: "00ff00",
+});
+
+const device = new netbox.Device("device", {
+    name: "example-device",
+    deviceTypeId: ref(deviceType),
+    roleId: ref(role),
+    siteId: ref(site),
+});
+const iface = new netbox.DeviceInterface("eth0", {
+    name: "eth0",
+    type: "1000base-t",
+    deviceId: ref(device),
+});
+
+const ip = new netbox.IpAddress("ip", {
+    ipAddress: "10.10.0.10/24",
+    status: "active",
+    deviceInterfaceId: ref(iface),
+});
+
+export const siteId = site.id;
+export const prefixCidr = prefix.prefix;
+export const deviceName = device.name;
+export const ipAddress = ip.ipAddress;
@@ -0,0 +1,47 @@
+import pulumi
+import spk_pulumi_netbox as netbox
+
+
+def ref(resource):
+    """NetBox references other objects by their integer ID."""
+    return resource.id.apply(int)
+
+
+site = netbox.Site("site", name="example-site", slug="example-site", status="active")
+
+prefix = netbox.Prefix(
+    "prefix",
+    prefix="10.10.0.0/24",
+    status="active",
+    site_id=ref(site),
+    description="example servers",
+)
+
+manufacturer = netbox.Manufacturer("manufacturer", name="example-manufacturer")
+device_type = netbox.DeviceType(
+    "device-type", model="example-model", manufacturer_id=ref(manufacturer)
+)
+role = netbox.DeviceRole("role", name="example-server", color_hex="00ff00")
+
+device = netbox.Device(
+    "device",
+    name="example-device",
+    device_type_id=ref(device_type),
+    role_id=ref(role),
+    site_id=ref(site),
+)
+iface = netbox.DeviceInterface(
+    "eth0", name="eth0", type="1000base-t", device_id=ref(device)
+)
+
+ip = netbox.IpAddress(
+    "ip",
+    ip_address="10.10.0.10/24",
+    status="active",
+    device_interface_id=ref(iface),
+)
+
+pulumi.export("siteId", site.id)
+pulumi.export("prefixCidr", prefix.prefix)
+pulumi.export("deviceName", device.name)
+pulumi.export("ipAddress", ip.ip_address)
@@ -0,0 +1,218 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// keyField is a property of a natural key and the API filter matching it.
+type keyField struct {
+	attr   string
+	filter string
+}
+
+// naturalKeys are the properties identifying an existing object of each
+// resource that can adopt one: the slug of models that have one, else a name
+// or number. An unset scope, such as a VLAN without a group, only matches
+// objects without one.
+var naturalKeys = map[string][]keyField{
+	"netbox_aggregate":               {{"prefix", "prefix"}},
+	"netbox_asn":                     {{"asn", "asn"}},
+	"netbox_circuit":                 {{"cid", "cid"}, {"provider_id", "provider_id"}},
+	"netbox_circuit_provider":        {{"slug", "slug"}},
+	"netbox_circuit_type":            {{"slug", "slug"}},
+	"netbox_cluster":                 {{"name", "name"}, {"cluster_group_id", "group_id"}},
+	"netbox_cluster_group":           {{"slug", "slug"}},
+	"netbox_cluster_type":            {{"slug", "slug"}},
+	"netbox_contact_group":           {{"slug", "slug"}, {"parent_id", "parent_id"}},
+	"netbox_contact_role":            {{"slug", "slug"}},
+	"netbox_custom_field":            {{"name", "name"}},
+	"netbox_custom_field_choice_set": {{"name", "name"}},
+	"netbox_device":                  {{"name", "name"}, {"site_id", "site_id"}, {"tenant_id", "tenant_id"}},
+	"netbox_device_interface":        {{"name", "name"}, {"device_id", "device_id"}},
+	"netbox_device_role":             {{"slug", "slug"}},
+	"netbox_device_type":             {{"slug", "slug"}, {"manufacturer_id", "manufacturer_id"}},
+	"netbox_event_rule":              {{"name", "name"}},
+	"netbox_interface":               {{"name", "name"}, {"virtual_machine_id", "virtual_machine_id"}},
+	"netbox_inventory_item_role":     {{"slug", "slug"}},
+	"netbox_ip_address":              {{"ip_address", "address"}, {"vrf_id", "vrf_id"}},
+	"netbox_ipam_role":               {{"slug", "slug"}},
+	"netbox_location":                {{"slug", "slug"}, {"site_id", "site_id"}, {"parent_id", "parent_id"}},
+	"netbox_manufacturer":            {{"slug", "slug"}},
+	"netbox_module_type":             {{"model", "model"}, {"manufacturer_id", "manufacturer_id"}},
+	"netbox_permission":              {{"name", "name"}},
+	"netbox_platform":                {{"slug", "slug"}},
+	"netbox_power_panel":             {{"name", "name"}, {"site_id", "site_id"}},
+	"netbox_prefix":                  {{"prefix", "prefix"}, {"vrf_id", "vrf_id"}},
+	"netbox_rack":                    {{"name", "name"}, {"site_id", "site_id"}, {"location_id", "location_id"}},
+	"netbox_rack_role":               {{"slug", "slug"}},
+	"netbox_region":                  {{"slug", "slug"}, {"parent_region_id", "parent_id"}},
+	"netbox_rir":                     {{"slug", "slug"}},
+	"netbox_route_target":            {{"name", "name"}, {"tenant_id", "tenant_id"}},
+	"netbox_site":                    {{"slug", "slug"}},
+	"netbox_site_group":              {{"slug", "slug"}, {"parent_id", "parent_id"}},
+	"netbox_tag":                     {{"slug", "slug"}},
+	"netbox_tenant":                  {{"slug", "slug"}},
+	"netbox_tenant_group":            {{"slug", "slug"}},
+	"netbox_user":                    {{"username", "username"}},
+	"netbox_virtual_chassis":         {{"name", "name"}},
+	"netbox_virtual_machine":         {{"name", "name"}, {"cluster_id", "cluster_id"}, {"tenant_id", "tenant_id"}},
+	"netbox_vlan":                    {{"vid", "vid"}, {"group_id", "group_id"}, {"site_id", "site_id"}},
+	"netbox_vlan_group":              {{"slug", "slug"}, {"scope_type", "scope_type"}, {"scope_id", "scope_id"}},
+	"netbox_vpn_tunnel_group":        {{"slug", "slug"}},
+	"netbox_webhook":                 {{"name", "name"}},
+}
+
+// slugSources are the properties the slug of a resource is generated from
+// when it is not set, other than name.
+var slugSources = map[string]string{
+	"netbox_device_type": "model",
+}
+
+// anyScope lists the filters NetBox cannot match against an unset value. An
+// unset value of these is left out of the natural key.
+var anyScope = map[string]bool{
+	"scope_type": true,
+	"scope_id":   true,
+}
+
+// adoptingKey marks the context of the update that adopts an existing object.
+type adoptingKey struct{}
+
+func adopting(ctx context.Context) bool {
+	v, _ := ctx.Value(adoptingKey{}).(bool)
+	return v
+}
+
+// adoptResource lets r adopt the object matching its natural key on create,
+// and update it to the desired state, instead of creating a duplicate.
+func adoptResource(name string, r *schema.Resource) {
+	keys, ok := naturalKeys[name]
+	if !ok {
+		return
+	}
+	endpoint := objectTypes[name].endpoint
+
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = "`" + pulumiName(r, k.attr) + "`"
+	}
+	r.Schema["adopt_existing"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "On create, adopt the existing NetBox object with the same " + strings.Join(names, ", ") + " and update it to the desired state, instead of creating a new one.",
+	}
+
+	create := r.CreateContext
+	r.CreateContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		if adopt, _ := d.Get("adopt_existing").(bool); !adopt {
+			return create(ctx, d, meta)
+		}
+		query := naturalKey(name, keys, d)
+		id, err := findObject(ctx, meta, endpoint, query)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if id == "" {
+			return create(ctx, d, meta)
+		}
+
+		d.SetId(id)
+		ctx = context.WithValue(ctx, adoptingKey{}, true)
+		diags := r.UpdateContext(ctx, d, withContext(ctx, meta))
+		if diags.HasError() {
+			d.SetId("")
+			return diags
+		}
+		return append(diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Adopted an existing NetBox object",
+			Detail: fmt.Sprintf("%s adopted %s %s, matching %s, and updated it to the desired state instead of creating it.",
+				describeResource(ctx, name), endpoint, id, describeQuery(query)),
+		}}, diags...)
+	}
+}
+
+// naturalKey returns the filters matching the natural key of d, a resource
+// named name. An unset slug is generated as the resource would on create.
+func naturalKey(name string, keys []keyField, d *schema.ResourceData) url.Values {
+	query := url.Values{}
+	for _, k := range keys {
+		v := fmt.Sprint(d.Get(k.attr))
+		if k.attr == "slug" && v == "" {
+			source := "name"
+			if s, ok := slugSources[name]; ok {
+				source = s
+			}
+			v = generatedSlug(fmt.Sprint(d.Get(source)))
+		}
+		if v == "" || v == "0" {
+			if anyScope[k.filter] {
+				continue
+			}
+			v = "null"
+		}
+		query.Set(k.filter, v)
+	}
+	return query
+}
+
+var (
+	slugSpecial = regexp.MustCompile(`[^\w\s-]`)
+	slugSpaces  = regexp.MustCompile(`[\s-]+`)
+)
+
+// generatedSlug returns the slug resources generate from name when none is
+// set, as the upstream provider does.
+func generatedSlug(name string) string {
+	slug := slugSpaces.ReplaceAllString(slugSpecial.ReplaceAllString(name, ""), "-")
+	return strings.ToLower(strings.Trim(slug, "-"))
+}
+
+// findObject returns the ID of the single object of endpoint matching query,
+// or an empty ID if there is none.
+func findObject(ctx context.Context, meta interface{}, endpoint string, query url.Values) (string, error) {
+	res, err := apiCall(ctx, meta, http.MethodGet, endpoint+"/", query, nil)
+	if err != nil {
+		return "", err
+	}
+	results, _ := res.(map[string]interface{})["results"].([]interface{})
+	switch len(results) {
+	case 0:
+		return "", nil
+	case 1:
+		return fmt.Sprint(refID(results[0])), nil
+	}
+	return "", fmt.Errorf("cannot adopt an existing object: %d objects of %s match %s", len(results), endpoint, describeQuery(query))
+}
+
+func describeQuery(query url.Values) string {
+	var parts []string
+	for k := range query {
+		parts = append(parts, k+"="+query.Get(k))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
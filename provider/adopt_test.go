@@ -0,0 +1,183 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestNaturalKeys(t *testing.T) {
+	p := netboxProvider()
+	for name, keys := range naturalKeys {
+		r, ok := p.ResourcesMap[name]
+		if !ok {
+			t.Errorf("%s is not a resource", name)
+			continue
+		}
+		if _, ok := objectTypes[name]; !ok {
+			t.Errorf("%s has no object type", name)
+		}
+		for _, k := range keys {
+			if _, ok := r.Schema[k.attr]; !ok {
+				t.Errorf("%s has no %s property", name, k.attr)
+			}
+		}
+	}
+}
+
+func TestAdoptExisting(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	group := netbox.Create("ipam/vlan-groups", map[string]interface{}{"name": "dc1", "slug": "dc1", "min_vid": 1, "max_vid": 4094})
+	site := netbox.Create("dcim/sites", map[string]interface{}{"name": "par1", "slug": "par1", "status": "planned"})
+	vlan := netbox.Create("ipam/vlans", map[string]interface{}{"name": "old", "vid": 100, "group": group, "status": "active"})
+	manufacturer := netbox.Create("dcim/manufacturers", map[string]interface{}{"name": "Juniper", "slug": "juniper-networks"})
+	p := newTestProvider(t, netbox, nil)
+
+	tests := []struct {
+		name     string
+		tfName   string
+		inputs   map[string]interface{}
+		endpoint string
+		// adopted is the ID of the object to adopt, or 0 if one is created.
+		adopted int64
+		check   func(t *testing.T, obj map[string]interface{})
+	}{
+		{
+			name:     "site",
+			tfName:   "netbox_site",
+			inputs:   map[string]interface{}{"name": "par1", "status": "active", "adoptExisting": true},
+			endpoint: "dcim/sites",
+			adopted:  site,
+			check: func(t *testing.T, obj map[string]interface{}) {
+				if status, _ := obj["status"].(map[string]interface{}); status["value"] != "active" {
+					t.Errorf("status %v, want active", obj["status"])
+				}
+			},
+		},
+		{
+			name:     "manufacturer by slug",
+			tfName:   "netbox_manufacturer",
+			inputs:   map[string]interface{}{"name": "Juniper Networks", "adoptExisting": true},
+			endpoint: "dcim/manufacturers",
+			adopted:  manufacturer,
+			check: func(t *testing.T, obj map[string]interface{}) {
+				if obj["name"] != "Juniper Networks" {
+					t.Errorf("name %v, want Juniper Networks", obj["name"])
+				}
+			},
+		},
+		{
+			name:     "manufacturer with another slug",
+			tfName:   "netbox_manufacturer",
+			inputs:   map[string]interface{}{"name": "Juniper", "slug": "juniper", "adoptExisting": true},
+			endpoint: "dcim/manufacturers",
+		},
+		{
+			name:     "vlan group without scope",
+			tfName:   "netbox_vlan_group",
+			inputs:   map[string]interface{}{"name": "dc1", "slug": "dc1", "minVid": 1, "maxVid": 4094, "adoptExisting": true},
+			endpoint: "ipam/vlan-groups",
+			adopted:  group,
+			check:    func(t *testing.T, obj map[string]interface{}) {},
+		},
+		{
+			name:     "vlan in group",
+			tfName:   "netbox_vlan",
+			inputs:   map[string]interface{}{"name": "users", "vid": 100, "groupId": group, "adoptExisting": true},
+			endpoint: "ipam/vlans",
+			adopted:  vlan,
+			check: func(t *testing.T, obj map[string]interface{}) {
+				if obj["name"] != "users" {
+					t.Errorf("name %v, want users", obj["name"])
+				}
+			},
+		},
+		{
+			name:     "vlan without group",
+			tfName:   "netbox_vlan",
+			inputs:   map[string]interface{}{"name": "users", "vid": 100, "adoptExisting": true},
+			endpoint: "ipam/vlans",
+		},
+		{
+			name:     "not requested",
+			tfName:   "netbox_site",
+			inputs:   map[string]interface{}{"name": "par1"},
+			endpoint: "dcim/sites",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urn := p.urn(tt.tfName, "test")
+			id, _ := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(tt.inputs)))
+			objectID, _ := strconv.ParseInt(id, 10, 64)
+			if tt.adopted == 0 {
+				if objectID == site || objectID == vlan || objectID == manufacturer {
+					t.Fatalf("adopted %s %d, want a new object", tt.endpoint, objectID)
+				}
+				return
+			}
+			if objectID != tt.adopted {
+				t.Fatalf("got %s %d, want %d adopted", tt.endpoint, objectID, tt.adopted)
+			}
+			tt.check(t, getObject(netbox, tt.endpoint, objectID))
+		})
+	}
+}
+
+func TestAdoptExistingAmbiguous(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	for i := 0; i < 2; i++ {
+		netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/24", "status": "active"})
+	}
+	p := newTestProvider(t, netbox, nil)
+
+	urn := p.urn("netbox_prefix", "test")
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"prefix": "10.0.0.0/24", "status": "active", "adoptExisting": true,
+	}))
+	_, _, err := p.tryCreate(urn, inputs)
+	if err == nil || !strings.Contains(err.Error(), "2 objects of ipam/prefixes match") {
+		t.Fatalf("got %v, want an ambiguous match error", err)
+	}
+	if prefixes := netbox.List("ipam/prefixes"); len(prefixes) != 2 {
+		t.Errorf("got %d prefixes, want 2", len(prefixes))
+	}
+}
+
+func TestAdoptExistingOwnership(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	unowned := netbox.Create("dcim/sites", map[string]interface{}{"name": "par1", "slug": "par1", "status": "active"})
+	p := ownershipProvider(t, netbox, map[string]interface{}{})
+
+	urn := p.urn("netbox_site", "test")
+	id, _ := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"name": "par1", "adoptExisting": true,
+	})))
+	if id != strconv.FormatInt(unowned, 10) {
+		t.Fatalf("got site %s, want %d adopted", id, unowned)
+	}
+	if !hasTag(getObject(netbox, "dcim/sites", unowned), "pulumi:netbox/test") {
+		t.Errorf("adopted site is not tagged with its stack")
+	}
+}
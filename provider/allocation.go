@@ -0,0 +1,629 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// allocationWindow is how long the first allocation from a parent waits for
+// the others the engine starts with it, so they are served in URN order.
+var allocationWindow = 100 * time.Millisecond
+
+// allocationRetries is how many times an allocation NetBox reports as
+// conflicting with a concurrent one is retried, with allocationBackoff
+// doubling between attempts.
+var (
+	allocationRetries = 5
+	allocationBackoff = 200 * time.Millisecond
+)
+
+// allocationSpec describes how a resource allocates its objects from the
+// available-* endpoint of a parent.
+type allocationSpec struct {
+	// endpoint holds the allocated objects.
+	endpoint string
+	// parent returns the endpoint to allocate from, such as
+	// ipam/prefixes/1/available-ips/.
+	parent func(d *schema.ResourceData) string
+	// request returns the fields to request on top of fields, such as the
+	// prefix length.
+	request func(d *schema.ResourceData) map[string]interface{}
+	// contiguous returns the first count consecutive values of field among
+	// the free ones the parent lists.
+	contiguous func(d *schema.ResourceData, free []interface{}, count int) ([]string, error)
+	// attr is the property set from field of the first allocated object, and
+	// list and ids the properties listing the field and ID of every one.
+	attr, field, list, ids string
+	// fields maps the properties applied to every allocated object to their
+	// NetBox fields.
+	fields map[string]string
+}
+
+// allocations are the upstream resources whose allocation is replaced, so it
+// is serialized per parent and retried, and may allocate several objects.
+var allocations = map[string]allocationSpec{
+	"netbox_available_ip_address": {
+		endpoint: "ipam/ip-addresses",
+		parent: func(d *schema.ResourceData) string {
+			if id, _ := d.Get("prefix_id").(int); id != 0 {
+				return fmt.Sprintf("ipam/prefixes/%d/available-ips/", id)
+			}
+			return fmt.Sprintf("ipam/ip-ranges/%d/available-ips/", d.Get("ip_range_id").(int))
+		},
+		request:    func(*schema.ResourceData) map[string]interface{} { return nil },
+		contiguous: contiguousAddresses,
+		attr:       "ip_address",
+		field:      "address",
+		list:       "ip_addresses",
+		ids:        "ip_address_ids",
+		fields: map[string]string{
+			"description": "description",
+			"dns_name":    "dns_name",
+			"role":        "role",
+			"status":      "status",
+			"tenant_id":   "tenant",
+			"vrf_id":      "vrf",
+			"tags":        "tags",
+		},
+	},
+	"netbox_available_prefix": {
+		endpoint: "ipam/prefixes",
+		parent: func(d *schema.ResourceData) string {
+			return fmt.Sprintf("ipam/prefixes/%d/available-prefixes/", d.Get("parent_prefix_id").(int))
+		},
+		request: func(d *schema.ResourceData) map[string]interface{} {
+			return map[string]interface{}{"prefix_length": d.Get("prefix_length")}
+		},
+		contiguous: contiguousPrefixes,
+		attr:       "prefix",
+		field:      "prefix",
+		list:       "prefixes",
+		ids:        "prefix_ids",
+		fields: map[string]string{
+			"description":   "description",
+			"is_pool":       "is_pool",
+			"mark_utilized": "mark_utilized",
+			"role_id":       "role",
+			"site_id":       "site",
+			"status":        "status",
+			"tenant_id":     "tenant",
+			"vlan_id":       "vlan",
+			"vrf_id":        "vrf",
+			"tags":          "tags",
+		},
+	},
+}
+
+// allocationResource replaces the allocation made by the create of r with one
+// serialized with the other allocations from the same parent, that allocates
+// count objects at once, then applies the rest of the inputs to the first one
+// with the update of r, as upstream does. The other objects are kept in step
+// with the first on read, update and delete.
+func allocationResource(name string, r *schema.Resource) {
+	spec, ok := allocations[name]
+	if !ok {
+		return
+	}
+	// count is reserved in Terraform, so the property is mapped to it in
+	// resources.go.
+	r.Schema["allocation_count"] = &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		ForceNew:     true,
+		ValidateFunc: validation.IntAtLeast(1),
+		Description:  "The number of objects to allocate at once. Defaults to 1.",
+	}
+	r.Schema["contiguous"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		ForceNew:    true,
+		Description: "Allocate consecutive objects, failing if the parent has no such run free.",
+	}
+	r.Schema[spec.list] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+		Description: "Every allocated `" + pulumiName(r, spec.attr) + "`, in order.",
+	}
+	r.Schema[spec.ids] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem:        &schema.Schema{Type: schema.TypeInt},
+		Description: "The IDs of the allocated objects, in order.",
+	}
+
+	read, update, del := r.ReadContext, r.UpdateContext, r.DeleteContext
+	r.CreateContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		objects, err := allocateObjects(ctx, spec, d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		values := make([]interface{}, len(objects))
+		ids := make([]interface{}, len(objects))
+		for i, obj := range objects {
+			values[i], ids[i] = obj[spec.field], int(refID(obj))
+		}
+		d.SetId(strconv.Itoa(ids[0].(int)))
+		for k, v := range map[string]interface{}{spec.attr: values[0], spec.list: values, spec.ids: ids} {
+			if err := d.Set(k, v); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+		return update(ctx, d, meta)
+	}
+	r.ReadContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		diags := read(ctx, d, meta)
+		if diags.HasError() || d.Id() == "" {
+			return diags
+		}
+		if err := readAllocation(ctx, spec, d, meta); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		return diags
+	}
+	r.UpdateContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		diags := update(ctx, d, meta)
+		others := allocatedIDs(name, d)[1:]
+		if diags.HasError() || len(others) == 0 || !d.HasChanges(fieldAttrs(spec.fields)...) {
+			return diags
+		}
+		body := objectBody(d, spec.fields)
+		patch := make([]interface{}, len(others))
+		for i, id := range others {
+			obj := map[string]interface{}{"id": json.Number(id)}
+			for k, v := range body {
+				obj[k] = v
+			}
+			patch[i] = obj
+		}
+		if _, err := apiCall(ctx, meta, http.MethodPatch, spec.endpoint+"/", nil, patch); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		return diags
+	}
+	r.DeleteContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		// The other objects go first, so the resource remains while any is left.
+		if others := allocatedIDs(name, d)[1:]; len(others) > 0 {
+			body := make([]interface{}, len(others))
+			for i, id := range others {
+				body[i] = map[string]interface{}{"id": json.Number(id)}
+			}
+			if _, err := apiCall(ctx, meta, http.MethodDelete, spec.endpoint+"/", nil, body); err != nil {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+					return diag.FromErr(err)
+				}
+			}
+		}
+		return del(ctx, d, meta)
+	}
+}
+
+// allocatedIDs returns the IDs of the objects of the resource name, the first
+// being its own.
+func allocatedIDs(name string, d *schema.ResourceData) []string {
+	if spec, ok := allocations[name]; ok {
+		ids, _ := d.Get(spec.ids).([]interface{})
+		if len(ids) > 1 {
+			out := make([]string, len(ids))
+			for i, id := range ids {
+				out[i] = fmt.Sprint(id)
+			}
+			return out
+		}
+	}
+	return []string{d.Id()}
+}
+
+// fieldAttrs returns the properties of fields, which maps properties to
+// NetBox fields.
+func fieldAttrs(fields map[string]string) []string {
+	attrs := make([]string, 0, len(fields))
+	for attr := range fields {
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+// objectBody returns the NetBox fields set by the properties of d. Unset
+// references are null, and tags are nested by name.
+func objectBody(d *schema.ResourceData, fields map[string]string) map[string]interface{} {
+	body := map[string]interface{}{}
+	for attr, field := range fields {
+		switch v := d.Get(attr).(type) {
+		case *schema.Set:
+			tags := []interface{}{}
+			for _, name := range v.List() {
+				tags = append(tags, map[string]interface{}{"name": name})
+			}
+			body[field] = tags
+		case int:
+			if v == 0 && strings.HasSuffix(attr, "_id") {
+				body[field] = nil
+			} else {
+				body[field] = v
+			}
+		default:
+			body[field] = v
+		}
+	}
+	return body
+}
+
+// allocateObjects allocates the objects of d from its parent.
+func allocateObjects(ctx context.Context, spec allocationSpec, d *schema.ResourceData, meta interface{}) ([]map[string]interface{}, error) {
+	count, _ := d.Get("allocation_count").(int)
+	if count == 0 {
+		count = 1
+	}
+	contiguous, _ := d.Get("contiguous").(bool)
+	parent := spec.parent(d)
+	fields := objectBody(d, spec.fields)
+	for k, v := range spec.request(d) {
+		fields[k] = v
+	}
+
+	res, err := allocate(ctx, meta, parent, func() (interface{}, error) {
+		bodies := make([]interface{}, count)
+		if !contiguous || count == 1 {
+			for i := range bodies {
+				bodies[i] = fields
+			}
+			return apiCall(ctx, meta, http.MethodPost, parent, nil, bodies)
+		}
+
+		// NetBox allocates the first free objects, whether consecutive or
+		// not, so consecutive ones are picked here and created directly.
+		// That bypasses the lock NetBox holds on the parent while it
+		// allocates, so the objects are checked once created.
+		free, err := apiCall(ctx, meta, http.MethodGet, parent, url.Values{"limit": {strconv.Itoa(maxFreeObjects)}}, nil)
+		if err != nil {
+			return nil, err
+		}
+		list, _ := free.([]interface{})
+		values, err := spec.contiguous(d, list, count)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parent, err)
+		}
+		for i, v := range values {
+			body := map[string]interface{}{spec.field: v}
+			for k, v := range fields {
+				body[k] = v
+			}
+			delete(body, "prefix_length")
+			bodies[i] = body
+		}
+		created, err := apiCall(ctx, meta, http.MethodPost, spec.endpoint+"/", nil, bodies)
+		if err != nil {
+			return nil, err
+		}
+		return created, verifyAllocation(ctx, meta, spec, fields["vrf"], values, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	list, _ := res.([]interface{})
+	if len(list) != count {
+		return nil, fmt.Errorf("%s allocated %d objects instead of %d", parent, len(list), count)
+	}
+	objects := make([]map[string]interface{}, len(list))
+	for i, obj := range list {
+		objects[i], _ = obj.(map[string]interface{})
+	}
+	return objects, nil
+}
+
+// readAllocation refreshes the list and IDs of the objects of d, dropping
+// those deleted out of band.
+func readAllocation(ctx context.Context, spec allocationSpec, d *schema.ResourceData, meta interface{}) error {
+	ids, _ := d.Get(spec.ids).([]interface{})
+	values := []interface{}{d.Get(spec.attr)}
+	kept := []interface{}{d.Id()}
+	if len(ids) > 1 {
+		query := url.Values{"limit": {strconv.Itoa(len(ids))}}
+		for _, id := range ids[1:] {
+			query.Add("id", fmt.Sprint(id))
+		}
+		res, err := apiCall(ctx, meta, http.MethodGet, spec.endpoint+"/", query, nil)
+		if err != nil {
+			return err
+		}
+		found := map[int64]interface{}{}
+		results, _ := res.(map[string]interface{})["results"].([]interface{})
+		for _, obj := range results {
+			obj, _ := obj.(map[string]interface{})
+			found[refID(obj)] = obj[spec.field]
+		}
+		for _, id := range ids[1:] {
+			if v, ok := found[int64(id.(int))]; ok {
+				values = append(values, v)
+				kept = append(kept, id)
+			}
+		}
+	}
+	id, _ := strconv.Atoi(d.Id())
+	kept[0] = id
+	if err := d.Set(spec.list, values); err != nil {
+		return err
+	}
+	return d.Set(spec.ids, kept)
+}
+
+// allocate runs request, which allocates from the available-* endpoint
+// parent, once the allocations from parent started before it, or with a lower
+// URN, are done. It retries request while NetBox reports a conflict with a
+// concurrent allocation.
+func allocate(ctx context.Context, meta interface{}, parent string, request func() (interface{}, error)) (interface{}, error) {
+	release, err := configFrom(meta).allocator.acquire(ctx, parent, describeResource(ctx, ""))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	backoff := allocationBackoff
+	for attempt := 1; ; attempt++ {
+		res, err := request()
+		if err == nil || !allocationConflict(err) || attempt >= allocationRetries {
+			return res, err
+		}
+		debugf(ctx, "allocation from %s conflicted, retrying in %v: %v", parent, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// maxFreeObjects is how many free objects of a parent are considered for a
+// contiguous allocation, the most NetBox lists by default.
+const maxFreeObjects = 1000
+
+// contiguousAddresses returns the first count consecutive addresses among the
+// free ones.
+func contiguousAddresses(_ *schema.ResourceData, free []interface{}, count int) ([]string, error) {
+	var run []string
+	var last netip.Addr
+	for _, item := range free {
+		item, _ := item.(map[string]interface{})
+		s, _ := item["address"].(string)
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			continue
+		}
+		if !last.IsValid() || last.Next() != p.Addr() {
+			run = run[:0]
+		}
+		run = append(run, s)
+		last = p.Addr()
+		if len(run) == count {
+			return run, nil
+		}
+	}
+	return nil, fmt.Errorf("no %d consecutive addresses are free among the first %d available", count, maxFreeObjects)
+}
+
+// contiguousPrefixes returns the first count adjacent prefixes of the
+// requested length within the free blocks.
+func contiguousPrefixes(d *schema.ResourceData, free []interface{}, count int) ([]string, error) {
+	length := d.Get("prefix_length").(int)
+	// Walk the free blocks, merging adjacent ones, for the first aligned
+	// start with count prefixes of room after it.
+	one := big.NewInt(1)
+	var start, end *big.Int
+	for _, item := range free {
+		item, _ := item.(map[string]interface{})
+		s, _ := item["prefix"].(string)
+		b, err := netip.ParsePrefix(s)
+		if err != nil || length > b.Addr().BitLen() {
+			continue
+		}
+		b = b.Masked()
+		lo, hi := addrInt(b.Addr()), addrInt(lastAddr(b))
+		if start == nil || new(big.Int).Add(end, one).Cmp(lo) != 0 {
+			start = lo
+		}
+		end = hi
+
+		// first is start rounded up to a multiple of the prefix size, and
+		// stop is where count prefixes from there end.
+		size := new(big.Int).Lsh(one, uint(b.Addr().BitLen()-length))
+		first := new(big.Int).Add(start, size)
+		first.Sub(first, one).Div(first, size).Mul(first, size)
+		stop := new(big.Int).Mul(size, big.NewInt(int64(count)))
+		stop.Add(stop, first)
+		if stop.Cmp(new(big.Int).Add(end, one)) > 0 {
+			continue
+		}
+		prefixes := make([]string, count)
+		for i := range prefixes {
+			a := new(big.Int).Mul(size, big.NewInt(int64(i)))
+			prefixes[i] = netip.PrefixFrom(intAddr(a.Add(a, first), b.Addr().Is4()), length).String()
+		}
+		return prefixes, nil
+	}
+	return nil, fmt.Errorf("no %d adjacent /%d prefixes are free", count, length)
+}
+
+func addrInt(a netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(a.AsSlice())
+}
+
+func intAddr(i *big.Int, is4 bool) netip.Addr {
+	b := make([]byte, 16)
+	if is4 {
+		b = b[:4]
+	}
+	i.FillBytes(b)
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// lastAddr returns the last address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// errAllocationRaced is returned when another client created the same objects
+// as a contiguous allocation at the same time.
+var errAllocationRaced = errors.New("another client allocated the same objects concurrently")
+
+// verifyAllocation checks that the objects created with values in vrf are
+// the only ones with those values. Otherwise, a concurrent client took them
+// too, so the created objects are deleted and errAllocationRaced returned.
+func verifyAllocation(ctx context.Context, meta interface{}, spec allocationSpec, vrf interface{}, values []string, created interface{}) error {
+	query := url.Values{spec.field: values, "vrf_id": {"null"}}
+	if vrf != nil {
+		query.Set("vrf_id", fmt.Sprint(vrf))
+	}
+	objects, err := listObjects(ctx, meta, spec.endpoint+"/", query, 0)
+	if err != nil {
+		return err
+	}
+	list, _ := created.([]interface{})
+	if len(objects) <= len(list) {
+		return nil
+	}
+	ids := make([]interface{}, len(list))
+	for i, obj := range list {
+		ids[i] = map[string]interface{}{"id": refID(obj)}
+	}
+	if _, err := apiCall(ctx, meta, http.MethodDelete, spec.endpoint+"/", nil, ids); err != nil {
+		// Not retried, since the objects are left behind.
+		return fmt.Errorf("%v, and deleting them failed: %w", errAllocationRaced, err)
+	}
+	return errAllocationRaced
+}
+
+// allocationConflict reports whether err is NetBox refusing an allocation
+// because a concurrent one took the same object: a duplicate error, or a
+// database integrity error on versions that do not lock allocations. A
+// contiguous allocation another client raced is a conflict as well.
+func allocationConflict(err error) bool {
+	if errors.Is(err, errAllocationRaced) {
+		return true
+	}
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	body := strings.ToLower(apiErr.Body)
+	switch apiErr.Status {
+	case http.StatusBadRequest:
+		return strings.Contains(body, "duplicate")
+	case http.StatusInternalServerError:
+		return strings.Contains(body, "integrityerror") || strings.Contains(body, "duplicate key")
+	}
+	return false
+}
+
+// allocator serializes the allocations made from each parent.
+type allocator struct {
+	mu     sync.Mutex
+	queues map[string]*allocationQueue
+}
+
+// allocationQueue holds the allocations waiting for a parent.
+type allocationQueue struct {
+	mu      sync.Mutex
+	waiting []*allocationTurn
+	busy    bool
+}
+
+type allocationTurn struct {
+	urn   string
+	ready chan struct{}
+}
+
+// acquire waits for the turn of urn to allocate from parent, and returns the
+// function ending it. Turns are granted one at a time, in URN order among
+// those waiting, the first one after allocationWindow.
+func (a *allocator) acquire(ctx context.Context, parent, urn string) (func(), error) {
+	a.mu.Lock()
+	if a.queues == nil {
+		a.queues = map[string]*allocationQueue{}
+	}
+	q, ok := a.queues[parent]
+	if !ok {
+		q = &allocationQueue{}
+		a.queues[parent] = q
+	}
+	a.mu.Unlock()
+
+	turn := &allocationTurn{urn: urn, ready: make(chan struct{})}
+	q.mu.Lock()
+	q.waiting = append(q.waiting, turn)
+	if !q.busy {
+		q.busy = true
+		time.AfterFunc(allocationWindow, q.next)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-turn.ready:
+		return q.next, nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	for i, t := range q.waiting {
+		if t == turn {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+	// The turn was granted meanwhile: hand it on.
+	q.next()
+	return nil, ctx.Err()
+}
+
+// next grants the turn of the lowest URN waiting, if any.
+func (q *allocationQueue) next() {
+	q.mu.Lock()
+	if len(q.waiting) == 0 {
+		q.busy = false
+		q.mu.Unlock()
+		return
+	}
+	sort.SliceStable(q.waiting, func(i, j int) bool { return q.waiting[i].urn < q.waiting[j].urn })
+	turn := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	q.mu.Unlock()
+	close(turn.ready)
+}
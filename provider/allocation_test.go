@@ -0,0 +1,260 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestAllocationOrder(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	prefix := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.1.0.0/24", "status": "active"})
+	p := newTestProvider(t, netbox, nil)
+
+	tests := []struct {
+		tfName string
+		inputs map[string]interface{}
+		// want are the allocations expected for the resources named a, b, c
+		// and d.
+		want []string
+		prop string
+	}{
+		{
+			"netbox_available_ip_address",
+			map[string]interface{}{"prefixId": float64(prefix)},
+			[]string{"10.1.0.1/24", "10.1.0.2/24", "10.1.0.3/24", "10.1.0.4/24"},
+			"ipAddress",
+		},
+		{
+			"netbox_available_prefix",
+			map[string]interface{}{"parentPrefixId": float64(prefix), "prefixLength": 28.0, "status": "active"},
+			[]string{"10.1.0.0/28", "10.1.0.16/28", "10.1.0.32/28", "10.1.0.48/28"},
+			"prefix",
+		},
+	}
+	names := []string{"a", "b", "c", "d"}
+	for _, tt := range tests {
+		urns := make([]resource.URN, len(names))
+		inputs := make([]resource.PropertyMap, len(names))
+		for i, name := range names {
+			urns[i] = p.urn(tt.tfName, name)
+			inputs[i] = p.check(urns[i], nil, resource.NewPropertyMapFromMap(tt.inputs))
+		}
+
+		// Start the creates in reverse URN order.
+		got := make([]string, len(names))
+		var wg sync.WaitGroup
+		for i := len(names) - 1; i >= 0; i-- {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, props, err := p.tryCreate(urns[i], inputs[i])
+				if err != nil {
+					t.Errorf("%s %s: %v", tt.tfName, names[i], err)
+					return
+				}
+				got[i] = props[resource.PropertyKey(tt.prop)].StringValue()
+			}(i)
+			time.Sleep(10 * time.Millisecond)
+		}
+		wg.Wait()
+		for i := range names {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s %s: got %s %q, want %q", tt.tfName, names[i], tt.prop, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestAllocationRetry(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	prefix := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.1.0.0/24", "status": "active"})
+	var attempts atomic.Int32
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/available-ips/") {
+			return false
+		}
+		if attempts.Add(1) > 2 {
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"address": ["Duplicate IP address found in global table: 10.1.0.1/24"]}`))
+		return true
+	})
+	p := newTestProvider(t, netbox, nil)
+
+	backoff := allocationBackoff
+	allocationBackoff = time.Millisecond
+	defer func() { allocationBackoff = backoff }()
+
+	urn := p.urn("netbox_available_ip_address", "a")
+	_, props := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{"prefixId": float64(prefix)})))
+	if got := props["ipAddress"].StringValue(); got != "10.1.0.1/24" {
+		t.Errorf("ipAddress = %q, want 10.1.0.1/24", got)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("allocation attempts = %d, want 3", got)
+	}
+
+	// An exhausted parent is not retried.
+	attempts.Store(2)
+	small := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.2.0.0/32", "status": "active", "is_pool": true})
+	netbox.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.2.0.0/32", "status": "active"})
+	// The address is taken after the plan, which saw it free.
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet || r.URL.Path != fmt.Sprintf("/api/ipam/prefixes/%d/available-ips/", small) {
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"family": 4, "address": "10.2.0.0/32"}]`))
+		return true
+	})
+	urn = p.urn("netbox_available_ip_address", "b")
+	_, _, err := p.tryCreate(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{"prefixId": float64(small)})))
+	if err == nil || !strings.Contains(err.Error(), "insufficient number of IP addresses") {
+		t.Errorf("create in a full prefix: got error %v", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("allocation attempts = %d, want 3", got)
+	}
+}
+
+func TestAllocationCount(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	addresses := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.3.0.0/24", "status": "active"})
+	netbox.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.3.0.2/24", "status": "active"})
+	prefixes := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.4.0.0/24", "status": "active"})
+	netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.4.0.16/28", "status": "active"})
+	p := newTestProvider(t, netbox, nil)
+
+	tests := []struct {
+		tfName, name string
+		inputs       map[string]interface{}
+		// endpoint holds the objects, and list and ids are the properties
+		// listing them.
+		endpoint, list, ids string
+		want                []string
+	}{
+		{
+			"netbox_available_ip_address", "spread",
+			map[string]interface{}{"prefixId": float64(addresses), "count": 3.0},
+			"ipam/ip-addresses", "ipAddresses", "ipAddressIds",
+			[]string{"10.3.0.1/24", "10.3.0.3/24", "10.3.0.4/24"},
+		},
+		{
+			"netbox_available_ip_address", "contiguous",
+			map[string]interface{}{"prefixId": float64(addresses), "count": 3.0, "contiguous": true},
+			"ipam/ip-addresses", "ipAddresses", "ipAddressIds",
+			[]string{"10.3.0.3/24", "10.3.0.4/24", "10.3.0.5/24"},
+		},
+		{
+			"netbox_available_prefix", "spread",
+			map[string]interface{}{"parentPrefixId": float64(prefixes), "prefixLength": 28.0, "status": "active", "count": 2.0},
+			"ipam/prefixes", "prefixes", "prefixIds",
+			[]string{"10.4.0.0/28", "10.4.0.32/28"},
+		},
+		{
+			"netbox_available_prefix", "contiguous",
+			map[string]interface{}{"parentPrefixId": float64(prefixes), "prefixLength": 27.0, "status": "active", "count": 2.0, "contiguous": true},
+			"ipam/prefixes", "prefixes", "prefixIds",
+			// 10.4.0.0/28 is free but too small.
+			[]string{"10.4.0.32/27", "10.4.0.64/27"},
+		},
+	}
+	for _, tt := range tests {
+		urn := p.urn(tt.tfName, tt.name)
+		inputs := resource.NewPropertyMapFromMap(tt.inputs)
+		inputs["description"] = resource.NewStringProperty("bootstrap")
+		inputs = p.check(urn, nil, inputs)
+		id, state := p.create(urn, inputs)
+
+		var got []string
+		for _, v := range state[resource.PropertyKey(tt.list)].ArrayValue() {
+			got = append(got, v.StringValue())
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s %s: got %s %v, want %v", tt.tfName, tt.name, tt.list, got, tt.want)
+		}
+		ids := state[resource.PropertyKey(tt.ids)].ArrayValue()
+		if len(ids) != len(tt.want) || fmt.Sprint(ids[0].NumberValue()) != id {
+			t.Fatalf("%s %s: got %s %v for ID %s", tt.tfName, tt.name, tt.ids, ids, id)
+		}
+
+		// Updates and deletes apply to every object.
+		news := inputs.Copy()
+		news["description"] = resource.NewStringProperty("updated")
+		state = p.update(urn, id, state, news)
+		for _, v := range ids {
+			if obj := getObject(netbox, tt.endpoint, int64(v.NumberValue())); obj["description"] != "updated" {
+				t.Errorf("%s %s: object %v has description %v, want updated", tt.tfName, tt.name, v.NumberValue(), obj["description"])
+			}
+		}
+		p.delete(urn, id, state)
+		for _, v := range ids {
+			if obj := getObject(netbox, tt.endpoint, int64(v.NumberValue())); obj != nil {
+				t.Errorf("%s %s: object %v survived the delete", tt.tfName, tt.name, v.NumberValue())
+			}
+		}
+	}
+}
+
+func TestAllocationContiguousRace(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	prefix := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.6.0.0/24", "status": "active"})
+	// Another client takes 10.6.0.2 between the lookup of the free
+	// addresses and the create of the first attempt.
+	var raced atomic.Bool
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/ipam/ip-addresses/" && !raced.Swap(true) {
+			netbox.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.6.0.2/24", "status": "active"})
+		}
+		return false
+	})
+	p := newTestProvider(t, netbox, nil)
+
+	backoff := allocationBackoff
+	allocationBackoff = time.Millisecond
+	defer func() { allocationBackoff = backoff }()
+
+	urn := p.urn("netbox_available_ip_address", "run")
+	_, state := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"prefixId": float64(prefix), "count": 2.0, "contiguous": true,
+	})))
+	var got []string
+	for _, v := range state["ipAddresses"].ArrayValue() {
+		got = append(got, v.StringValue())
+	}
+	if strings.Join(got, " ") != "10.6.0.3/24 10.6.0.4/24" {
+		t.Errorf("ipAddresses = %v, want the run after the raced address", got)
+	}
+	if n := len(netbox.List("ipam/ip-addresses")); n != 3 {
+		t.Errorf("%d addresses left, want the raced one and the 2 allocated", n)
+	}
+}
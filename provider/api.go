@@ -0,0 +1,131 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	netboxclient "github.com/fbreckle/go-netbox/netbox/client"
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/strfmt"
+)
+
+// apiError is a NetBox API call answered with an error status.
+type apiError struct {
+	Method string
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.Path, e.Status, http.StatusText(e.Status), e.Body)
+}
+
+// apiCall makes a JSON API call the generated client does not model. path is
+// relative to the API root, such as dcim/devices/1/. The call goes through the
+// same transport as the generated client, so it is authenticated, traced and
+// logged alike. The decoded response body is returned.
+func apiCall(ctx context.Context, meta interface{}, method, path string, query url.Values, body interface{}) (interface{}, error) {
+	api, ok := meta.(*netboxclient.NetBoxAPI)
+	if !ok {
+		return nil, fmt.Errorf("unexpected provider meta %T", meta)
+	}
+	path = "/" + strings.TrimPrefix(path, "/")
+
+	var out interface{}
+	_, err := api.Transport.Submit(&runtime.ClientOperation{
+		ID:                 strings.ToLower(method) + " " + path,
+		Method:             method,
+		PathPattern:        path,
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"https"},
+		Context:            ctx,
+		Params: runtime.ClientRequestWriterFunc(func(req runtime.ClientRequest, _ strfmt.Registry) error {
+			for k, values := range query {
+				if err := req.SetQueryParam(k, values...); err != nil {
+					return err
+				}
+			}
+			if body != nil {
+				return req.SetBodyParam(body)
+			}
+			return nil
+		}),
+		// The runtime consumer decodes numbers as json.Number, so the body is
+		// decoded here instead.
+		Reader: runtime.ClientResponseReaderFunc(func(res runtime.ClientResponse, _ runtime.Consumer) (interface{}, error) {
+			b, err := io.ReadAll(res.Body())
+			if err != nil {
+				return nil, err
+			}
+			if res.Code() >= 300 {
+				return nil, &apiError{Method: method, Path: path, Status: res.Code(), Body: string(b)}
+			}
+			if len(bytes.TrimSpace(b)) == 0 {
+				return nil, nil
+			}
+			return nil, json.Unmarshal(b, &out)
+		}),
+	})
+	return out, err
+}
+
+// pageSize is the number of objects listObjects requests at a time, the
+// default maximum page size of NetBox.
+const pageSize = 1000
+
+// listObjects returns the objects of the list endpoint path matching query,
+// following the pages of the results. If limit is positive, at most limit
+// objects are returned.
+func listObjects(ctx context.Context, meta interface{}, path string, query url.Values, limit int) ([]map[string]interface{}, error) {
+	objects := []map[string]interface{}{}
+	for offset := 0; ; {
+		size := pageSize
+		if limit > 0 && limit-len(objects) < size {
+			size = limit - len(objects)
+		}
+		// The page is set last, so that query cannot override it.
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("limit", strconv.Itoa(size))
+		q.Set("offset", strconv.Itoa(offset))
+		res, err := apiCall(ctx, meta, http.MethodGet, path, q, nil)
+		if err != nil {
+			return nil, err
+		}
+		page, _ := res.(map[string]interface{})
+		results, _ := page["results"].([]interface{})
+		for _, item := range results {
+			obj, _ := item.(map[string]interface{})
+			objects = append(objects, obj)
+		}
+		offset += len(results)
+		if page["next"] == nil || len(results) == 0 || limit > 0 && len(objects) >= limit {
+			return objects, nil
+		}
+	}
+}
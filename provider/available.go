@@ -0,0 +1,299 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// availableObject describes a resource allocating one object from the
+// available-* endpoint of a parent, which the upstream provider lacks.
+type availableObject struct {
+	// endpoint holds the allocated objects.
+	endpoint string
+	// parent is the property naming the parent, and path the available-*
+	// endpoint of a parent ID. parentField is the NetBox field referencing
+	// the parent from the allocated object, if it has one.
+	parent, parentDescription string
+	path, parentField         string
+	// value is the property set from the allocated field, such as the VID.
+	value, valueDescription, field string
+	// fields maps the other properties to the NetBox fields they set.
+	fields map[string]string
+	// inherited are the properties NetBox sets from the parent when it
+	// allocates, which are applied after the allocation when configured.
+	inherited []string
+}
+
+// resource returns the resource allocating objects, with schema holding the
+// properties besides the parent and the allocated value.
+func (a availableObject) resource(description string, props map[string]*schema.Schema) *schema.Resource {
+	props[a.parent] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Required:    true,
+		ForceNew:    true,
+		Description: a.parentDescription,
+	}
+	props[a.value] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Computed:    true,
+		Description: a.valueDescription,
+	}
+	return &schema.Resource{
+		Description:   description,
+		Schema:        props,
+		CreateContext: a.create,
+		ReadContext:   a.read,
+		UpdateContext: a.update,
+		DeleteContext: a.delete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+	}
+}
+
+func (a availableObject) create(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	parent := fmt.Sprintf(a.path, d.Get(a.parent).(int))
+	fields := objectBody(d, a.fields)
+	overrides := map[string]string{}
+	for _, attr := range a.inherited {
+		if _, ok := d.GetOk(attr); ok {
+			overrides[attr] = a.fields[attr]
+		} else {
+			delete(fields, a.fields[attr])
+		}
+	}
+	body := []interface{}{fields}
+	res, err := allocate(ctx, meta, parent, func() (interface{}, error) {
+		return apiCall(ctx, meta, http.MethodPost, parent, nil, body)
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	list, _ := res.([]interface{})
+	if len(list) != 1 {
+		return diag.Errorf("%s allocated %d objects instead of 1", parent, len(list))
+	}
+	obj, _ := list[0].(map[string]interface{})
+	d.SetId(strconv.FormatInt(refID(obj), 10))
+
+	patch := map[string]interface{}{}
+	for attr, field := range overrides {
+		if int(refID(obj[field])) != d.Get(attr).(int) {
+			patch[field] = d.Get(attr)
+		}
+	}
+	if len(patch) > 0 {
+		res, err := apiCall(ctx, meta, http.MethodPatch, a.endpoint+"/"+d.Id()+"/", nil, patch)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		obj, _ = res.(map[string]interface{})
+	}
+	return a.set(d, obj)
+}
+
+func (a availableObject) read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	res, err := apiCall(ctx, meta, http.MethodGet, a.endpoint+"/"+d.Id()+"/", nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	obj, _ := res.(map[string]interface{})
+	return a.set(d, obj)
+}
+
+func (a availableObject) update(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	res, err := apiCall(ctx, meta, http.MethodPatch, a.endpoint+"/"+d.Id()+"/", nil, objectBody(d, a.fields))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	obj, _ := res.(map[string]interface{})
+	return a.set(d, obj)
+}
+
+func (a availableObject) delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	_, err := apiCall(ctx, meta, http.MethodDelete, a.endpoint+"/"+d.Id()+"/", nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil
+	}
+	return diag.FromErr(err)
+}
+
+// set sets the properties of d from the allocated object obj.
+func (a availableObject) set(d *schema.ResourceData, obj map[string]interface{}) diag.Diagnostics {
+	fields := map[string]string{a.value: a.field}
+	for attr, field := range a.fields {
+		fields[attr] = field
+	}
+	if a.parentField != "" {
+		// Read like the other _id fields, so an import finds the parent.
+		fields[a.parent] = a.parentField
+	}
+	return diag.FromErr(setObject(d, obj, fields))
+}
+
+// setObject sets the properties of d from the NetBox fields of obj.
+func setObject(d *schema.ResourceData, obj map[string]interface{}, fields map[string]string) error {
+	for attr, field := range fields {
+		var v interface{}
+		switch value := obj[field].(type) {
+		case map[string]interface{}:
+			// A nested object, or a choice.
+			if strings.HasSuffix(attr, "_id") {
+				v = int(refID(value))
+			} else {
+				v = value["value"]
+			}
+		case []interface{}:
+			names := make([]interface{}, 0, len(value))
+			for _, tag := range value {
+				tag, _ := tag.(map[string]interface{})
+				names = append(names, tag["name"])
+			}
+			v = names
+		case float64:
+			v = int(value)
+		default:
+			v = value
+		}
+		if err := d.Set(attr, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vlanStatuses are the statuses of a VLAN.
+var vlanStatuses = []string{"active", "reserved", "deprecated"}
+
+// availableVLAN allocates the next free VID of a VLAN group.
+var availableVLAN = availableObject{
+	endpoint:          "ipam/vlans",
+	parent:            "group_id",
+	parentDescription: "The ID of the VLAN group to allocate the VLAN ID from.",
+	path:              "ipam/vlan-groups/%d/available-vlans/",
+	parentField:       "group",
+	value:             "vid",
+	valueDescription:  "The allocated VLAN ID.",
+	field:             "vid",
+	fields: map[string]string{
+		"name":        "name",
+		"status":      "status",
+		"tenant_id":   "tenant",
+		"role_id":     "role",
+		"description": "description",
+		"tags":        "tags",
+	},
+}
+
+func resourceAvailableVLAN() *schema.Resource {
+	return availableVLAN.resource(`:meta:subcategory:IP Address Management (IPAM):This resource allocates the next free VLAN ID of a VLAN group (specified by ID), within its `+"`min_vid`-`max_vid`"+` range.`,
+		map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the VLAN.",
+			},
+			"status": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "active",
+				ValidateFunc: validation.StringInSlice(vlanStatuses, false),
+				Description:  "Valid values are `active`, `reserved` and `deprecated`.",
+			},
+			"tenant_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "The ID of the tenant of the VLAN.",
+			},
+			"role_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "The ID of the IPAM role of the VLAN.",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The description of the VLAN.",
+			},
+			"tags": tagsSchema(),
+		})
+}
+
+// availableASN allocates the next free ASN of an ASN range.
+var availableASN = availableObject{
+	endpoint:          "ipam/asns",
+	parent:            "asn_range_id",
+	parentDescription: "The ID of the ASN range to allocate the ASN from.",
+	path:              "ipam/asn-ranges/%d/available-asns/",
+	value:             "asn",
+	valueDescription:  "The allocated AS number.",
+	field:             "asn",
+	fields: map[string]string{
+		"rir_id":      "rir",
+		"tenant_id":   "tenant",
+		"description": "description",
+		"tags":        "tags",
+	},
+	inherited: []string{"rir_id"},
+}
+
+func resourceAvailableASN() *schema.Resource {
+	return availableASN.resource(`:meta:subcategory:IP Address Management (IPAM):This resource allocates the next free ASN of an ASN range (specified by ID), between its `+"`start`"+` and `+"`end`"+`. The ID of the resource is the ID of the ASN, as taken by `+"`netbox_site.asn_ids`"+`.`,
+		map[string]*schema.Schema{
+			"rir_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "The ID of the RIR of the ASN. Defaults to the RIR of the range.",
+			},
+			"tenant_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "The ID of the tenant of the ASN.",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The description of the ASN.",
+			},
+			"tags": tagsSchema(),
+		})
+}
+
+func tagsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeSet,
+		Optional:    true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+		Set:         schema.HashString,
+		Description: "The names of the tags of the object.",
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestAvailableVLAN(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	group := netbox.Create("ipam/vlan-groups", map[string]interface{}{"name": "dc1", "slug": "dc1", "min_vid": 100, "max_vid": 102})
+	netbox.Create("ipam/vlans", map[string]interface{}{"name": "taken", "vid": 101, "group": group, "status": "active"})
+	p := newTestProvider(t, netbox, nil)
+
+	names := []string{"a", "b"}
+	want := []float64{100, 102}
+	got := make([]float64, len(names))
+	var wg sync.WaitGroup
+	for i := len(names) - 1; i >= 0; i-- {
+		urn := p.urn("netbox_available_vlan", names[i])
+		inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+			"name": names[i], "groupId": float64(group), "tags": []interface{}{},
+		}))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, state, err := p.tryCreate(urn, inputs)
+			if err != nil {
+				t.Errorf("create %s: %v", names[i], err)
+				return
+			}
+			got[i] = state["vid"].NumberValue()
+		}(i)
+	}
+	wg.Wait()
+	for i := range names {
+		if got[i] != want[i] {
+			t.Errorf("%s: got vid %v, want %v", names[i], got[i], want[i])
+		}
+	}
+
+	urn := p.urn("netbox_available_vlan", "c")
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{"name": "c", "groupId": float64(group)}))
+	if _, _, err := p.tryCreate(urn, inputs); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("create in a full group: got error %v", err)
+	}
+
+	// A VLAN deleted out of band is gone on refresh, and deleting it again
+	// succeeds.
+	urn = p.urn("netbox_available_vlan", "a")
+	vlans := netbox.List("ipam/vlans")
+	var id int64
+	for _, vlan := range vlans {
+		if vlan["name"] == "a" {
+			id, _ = strconv.ParseInt(fmt.Sprint(vlan["id"]), 10, 64)
+		}
+	}
+	sid := strconv.FormatInt(id, 10)
+	_, state, _ := p.read(urn, sid, nil, nil)
+	netbox.Delete("ipam/vlans", id)
+	if gone, _, _ := p.read(urn, sid, state, nil); gone != "" {
+		t.Errorf("read of a deleted VLAN returned ID %q", gone)
+	}
+	if err := p.tryDelete(urn, sid, state); err != nil {
+		t.Errorf("delete of a deleted VLAN: %v", err)
+	}
+}
+
+func TestAvailableASN(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	rir := netbox.Create("ipam/rirs", map[string]interface{}{"name": "private", "slug": "private", "is_private": true})
+	asnRange := netbox.Create("ipam/asn-ranges", map[string]interface{}{"name": "dc", "slug": "dc", "rir": rir, "start": 65000, "end": 65010})
+	netbox.Create("ipam/asns", map[string]interface{}{"asn": 65000, "rir": rir})
+	var bodies []string
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/available-asns/") {
+			b, _ := io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(b))
+			bodies = append(bodies, string(b))
+		}
+		return false
+	})
+	p := newTestProvider(t, netbox, nil)
+
+	urn := p.urn("netbox_available_asn", "leaf")
+	id, state := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"asnRangeId": float64(asnRange), "description": "leaf",
+	})))
+	if got := state["asn"].NumberValue(); got != 65001 {
+		t.Errorf("asn = %v, want 65001", got)
+	}
+	if got := state["rirId"].NumberValue(); got != float64(rir) {
+		t.Errorf("rirId = %v, want %d", got, rir)
+	}
+	if len(bodies) != 1 || strings.Contains(bodies[0], "rir") {
+		t.Errorf("allocation requests = %v, want one leaving the RIR to the range", bodies)
+	}
+
+	// A RIR set explicitly replaces the one of the range.
+	other := netbox.Create("ipam/rirs", map[string]interface{}{"name": "arin", "slug": "arin"})
+	arinURN := p.urn("netbox_available_asn", "arin")
+	arinID, arin := p.create(arinURN, p.check(arinURN, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"asnRangeId": float64(asnRange), "rirId": float64(other),
+	})))
+	if got := arin["rirId"].NumberValue(); got != float64(other) {
+		t.Errorf("rirId = %v, want %d", got, other)
+	}
+	arinObjectID, _ := strconv.ParseInt(arinID, 10, 64)
+	if stored, _ := getObject(netbox, "ipam/asns", arinObjectID)["rir"].(map[string]interface{}); fmt.Sprint(stored["id"]) != fmt.Sprint(other) {
+		t.Errorf("stored RIR = %v, want %d", stored, other)
+	}
+
+	urn = p.urn("netbox_site", "dc")
+	siteID, _ := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"name": "dc", "status": "active", "asnIds": []interface{}{id},
+	})))
+	objectID, _ := strconv.ParseInt(siteID, 10, 64)
+	site := getObject(netbox, "dcim/sites", objectID)
+	if asns, _ := site["asns"].([]interface{}); len(asns) != 1 || fmt.Sprint(asns[0].(map[string]interface{})["id"]) != id {
+		t.Errorf("site ASNs = %v, want [%s]", site["asns"], id)
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+
+	netboxclient "github.com/fbreckle/go-netbox/netbox/client"
+	"github.com/fbreckle/go-netbox/netbox/client/ipam"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// parentSchema returns the properties naming the parent of a lookup: a prefix
+// by ID or by CIDR and VRF, and, if ranges is set, an IP range by ID.
+func parentSchema(ranges bool) map[string]*schema.Schema {
+	parents, ids := []string{"prefix_id", "prefix"}, []string{"prefix_id"}
+	if ranges {
+		parents, ids = append(parents, "ip_range_id"), append(ids, "ip_range_id")
+	}
+	props := map[string]*schema.Schema{
+		"prefix_id": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ExactlyOneOf: parents,
+		},
+		"prefix": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ExactlyOneOf: parents,
+			ValidateFunc: validation.IsCIDR,
+			Description:  "The CIDR of the prefix, looked up in `vrf_id`.",
+		},
+		"vrf_id": {
+			Type:          schema.TypeInt,
+			Optional:      true,
+			ConflictsWith: ids,
+			Description:   "The VRF `prefix` is looked up in. Defaults to the global table.",
+		},
+	}
+	if ranges {
+		props["ip_range_id"] = &schema.Schema{
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ExactlyOneOf: parents,
+		}
+	}
+	return props
+}
+
+// parentPath returns the API path of the prefix or IP range d names.
+func parentPath(ctx context.Context, d *schema.ResourceData, meta interface{}) (string, error) {
+	if id, _ := d.Get("prefix_id").(int); id != 0 {
+		return fmt.Sprintf("ipam/prefixes/%d/", id), nil
+	}
+	if id, _ := d.Get("ip_range_id").(int); id != 0 {
+		return fmt.Sprintf("ipam/ip-ranges/%d/", id), nil
+	}
+	id, err := lookupPrefix(ctx, meta, d.Get("prefix").(string), d.Get("vrf_id").(int))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ipam/prefixes/%d/", id), nil
+}
+
+// lookupPrefix returns the ID of the prefix with the CIDR cidr in the VRF vrf,
+// or in the global table if vrf is 0, filtered as by the netbox_prefix data
+// source.
+func lookupPrefix(ctx context.Context, meta interface{}, cidr string, vrf int) (int64, error) {
+	vrfID, table := "null", "the global table"
+	if vrf != 0 {
+		vrfID, table = strconv.Itoa(vrf), "VRF "+strconv.Itoa(vrf)
+	}
+	limit := int64(2)
+	params := ipam.NewIpamPrefixesListParams().WithContext(ctx).WithPrefix(&cidr).WithVrfID(&vrfID).WithLimit(&limit)
+	res, err := meta.(*netboxclient.NetBoxAPI).Ipam.IpamPrefixesList(params, nil)
+	if err != nil {
+		return 0, err
+	}
+	switch count := *res.GetPayload().Count; count {
+	case 0:
+		return 0, fmt.Errorf("no prefix %s in %s", cidr, table)
+	case 1:
+		return res.GetPayload().Results[0].ID, nil
+	default:
+		return 0, fmt.Errorf("%d prefixes %s in %s", count, cidr, table)
+	}
+}
+
+func countSchema(objects string) *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		Default:      1,
+		ValidateFunc: validation.IntBetween(1, maxFreeObjects),
+		Description:  fmt.Sprintf("The maximum number of %s to list. Defaults to 1.", objects),
+	}
+}
+
+func dataSourceAvailableIPs() *schema.Resource {
+	props := parentSchema(true)
+	props["result_count"] = countSchema("addresses")
+	props["ip_addresses"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+		Description: "The free IP addresses, in order, with the mask length of the parent.",
+	}
+	return &schema.Resource{
+		Description: `:meta:subcategory:IP Address Management (IPAM):Lists the first free IP addresses of a prefix or IP range, as ` + "`netbox_available_ip_address`" + ` would allocate them, without allocating them. Fewer than ` + "`result_count`" + ` addresses are returned when the parent has fewer free.`,
+		Schema:      props,
+		ReadContext: readAvailableIPs,
+	}
+}
+
+func readAvailableIPs(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	parent, err := parentPath(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	count := d.Get("result_count").(int)
+	res, err := apiCall(ctx, meta, http.MethodGet, parent+"available-ips/", url.Values{
+		"limit": {strconv.Itoa(count)},
+	}, nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	free, _ := res.([]interface{})
+	addresses := make([]string, 0, len(free))
+	for _, item := range free {
+		item, _ := item.(map[string]interface{})
+		if address, ok := item["address"].(string); ok && len(addresses) < count {
+			addresses = append(addresses, address)
+		}
+	}
+	d.SetId(parent)
+	return diag.FromErr(d.Set("ip_addresses", addresses))
+}
+
+func dataSourceAvailablePrefixes() *schema.Resource {
+	props := parentSchema(false)
+	props["result_count"] = countSchema("prefixes")
+	props["prefix_length"] = &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		ValidateFunc: validation.IntBetween(0, 128),
+		Description:  "The length of the prefixes to list. Defaults to the largest free blocks of the parent.",
+	}
+	props["prefixes"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+		Description: "The free prefixes, in order.",
+	}
+	return &schema.Resource{
+		Description: `:meta:subcategory:IP Address Management (IPAM):Lists the first free child prefixes of a prefix, of a given length or as the largest free blocks, without allocating them. Fewer than ` + "`result_count`" + ` prefixes are returned when the parent has room for fewer.`,
+		Schema:      props,
+		ReadContext: readAvailablePrefixes,
+	}
+}
+
+func readAvailablePrefixes(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	parent, err := parentPath(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	res, err := apiCall(ctx, meta, http.MethodGet, parent+"available-prefixes/", nil, nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	free, _ := res.([]interface{})
+	d.SetId(parent)
+	return diag.FromErr(d.Set("prefixes", candidatePrefixes(free, d.Get("prefix_length").(int), d.Get("result_count").(int))))
+}
+
+// candidatePrefixes returns the first count prefixes of length carved in
+// order from the free blocks, or the first count blocks if length is 0.
+func candidatePrefixes(free []interface{}, length, count int) []string {
+	prefixes := []string{}
+	one := big.NewInt(1)
+	for _, item := range free {
+		item, _ := item.(map[string]interface{})
+		s, _ := item["prefix"].(string)
+		b, err := netip.ParsePrefix(s)
+		if err != nil {
+			continue
+		}
+		b = b.Masked()
+		if length == 0 {
+			if len(prefixes) < count {
+				prefixes = append(prefixes, b.String())
+			}
+			continue
+		}
+		if length < b.Bits() || length > b.Addr().BitLen() {
+			continue
+		}
+		size := new(big.Int).Lsh(one, uint(b.Addr().BitLen()-length))
+		next, last := addrInt(b.Addr()), addrInt(lastAddr(b))
+		for ; next.Cmp(last) <= 0 && len(prefixes) < count; next = next.Add(next, size) {
+			prefixes = append(prefixes, netip.PrefixFrom(intAddr(next, b.Addr().Is4()), length).String())
+		}
+	}
+	return prefixes
+}
+
+func dataSourcePrefixUtilization() *schema.Resource {
+	props := parentSchema(false)
+	props["prefix_id"].Computed = true
+	props["prefix"].Computed = true
+	for key, description := range map[string]string{
+		"size": "The number of usable addresses of the prefix, capped at the largest 64-bit integer for large IPv6 prefixes. The network and broadcast addresses of IPv4 prefixes other than containers and pools are not counted.",
+		"used": "The number of addresses in use: those of the child prefixes for a container, else the IP addresses and the IP ranges marked utilized.",
+		"free": "The number of addresses not in use.",
+	} {
+		props[key] = &schema.Schema{
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: description,
+		}
+	}
+	props["utilization"] = &schema.Schema{
+		Type:        schema.TypeFloat,
+		Computed:    true,
+		Description: "The percentage of the prefix in use, as shown by NetBox. Prefixes marked utilized are 100% used.",
+	}
+	props["depth"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Computed:    true,
+		Description: "The number of prefixes of the same VRF containing the prefix, as NetBox stores it.",
+	}
+	props["child_prefixes"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "The prefixes directly under the prefix, in address order.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id":     {Type: schema.TypeInt, Computed: true},
+				"prefix": {Type: schema.TypeString, Computed: true},
+				"status": {Type: schema.TypeString, Computed: true},
+				"vrf_id": {Type: schema.TypeInt, Computed: true},
+			},
+		},
+	}
+	props["child_ip_ranges"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "The IP ranges directly under the prefix, outside of its child prefixes, in address order.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id":            {Type: schema.TypeInt, Computed: true},
+				"start_address": {Type: schema.TypeString, Computed: true},
+				"end_address":   {Type: schema.TypeString, Computed: true},
+				"size":          {Type: schema.TypeInt, Computed: true},
+				"mark_utilized": {Type: schema.TypeBool, Computed: true},
+			},
+		},
+	}
+	return &schema.Resource{
+		Description: `:meta:subcategory:IP Address Management (IPAM):Reports how much of a prefix is in use, computed as NetBox does, along with its direct child prefixes and IP ranges and its depth in the prefix hierarchy.`,
+		Schema:      props,
+		ReadContext: readPrefixUtilization,
+	}
+}
+
+func readPrefixUtilization(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	parent, err := parentPath(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	res, err := apiCall(ctx, meta, http.MethodGet, parent, nil, nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	prefix, _ := res.(map[string]interface{})
+	cidr, _ := prefix["prefix"].(string)
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return diag.Errorf("%s has an invalid prefix %q", parent, cidr)
+	}
+	p = p.Masked()
+	vrf := refID(prefix["vrf"])
+	status, _ := prefix["status"].(map[string]interface{})
+	container := status["value"] == "container"
+
+	// As in NetBox, a container of the global table holds the children of
+	// every VRF, and other prefixes those of their own VRF.
+	scope := func(filter, value string) url.Values {
+		q := url.Values{filter: {value}}
+		if vrf != 0 {
+			q.Set("vrf_id", strconv.FormatInt(vrf, 10))
+		} else if !container {
+			q.Set("vrf_id", "null")
+		}
+		return q
+	}
+	descendants, err := listObjects(ctx, meta, "ipam/prefixes/", scope("within", p.String()), 0)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ranges, err := listObjects(ctx, meta, "ipam/ip-ranges/", scope("parent", p.String()), 0)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	size := prefixSpan(p).size()
+	var used []span
+	utilized, _ := prefix["mark_utilized"].(bool)
+	switch {
+	case utilized:
+	case container:
+		for _, child := range descendants {
+			if c, err := netip.ParsePrefix(fmt.Sprint(child["prefix"])); err == nil {
+				used = append(used, prefixSpan(c.Masked()))
+			}
+		}
+	default:
+		if pool, _ := prefix["is_pool"].(bool); p.Addr().Is4() && p.Bits() < 31 && !pool {
+			size.Sub(size, big.NewInt(2))
+		}
+		for _, r := range ranges {
+			if utilized, _ := r["mark_utilized"].(bool); utilized {
+				if s, ok := rangeSpan(r); ok {
+					used = append(used, s)
+				}
+			}
+		}
+		addresses, err := listObjects(ctx, meta, "ipam/ip-addresses/", scope("parent", p.String()), 0)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		for _, ip := range addresses {
+			if a, err := netip.ParsePrefix(fmt.Sprint(ip["address"])); err == nil {
+				used = append(used, prefixSpan(netip.PrefixFrom(a.Addr(), a.Addr().BitLen())))
+			}
+		}
+	}
+	usedSize := unionSize(used)
+	if utilized || usedSize.Cmp(size) > 0 {
+		usedSize.Set(size)
+	}
+	utilization := 0.0
+	if size.Sign() > 0 {
+		utilization, _ = new(big.Float).Quo(new(big.Float).SetInt(usedSize), new(big.Float).SetInt(size)).Float64()
+		utilization *= 100
+	}
+
+	var children []netip.Prefix
+	for _, child := range descendants {
+		c, _ := netip.ParsePrefix(fmt.Sprint(child["prefix"]))
+		children = append(children, c.Masked())
+	}
+	order := make([]int, len(descendants))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return children[order[i]].Addr().Less(children[order[j]].Addr())
+	})
+	childPrefixes := []map[string]interface{}{}
+	for _, i := range order {
+		child := descendants[i]
+		if !children[i].IsValid() || within(children[i].Addr(), children[i].Bits(), children) {
+			continue
+		}
+		status, _ := child["status"].(map[string]interface{})
+		childPrefixes = append(childPrefixes, map[string]interface{}{
+			"id":     int(refID(child)),
+			"prefix": children[i].String(),
+			"status": status["value"],
+			"vrf_id": int(refID(child["vrf"])),
+		})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		a, _ := rangeSpan(ranges[i])
+		b, _ := rangeSpan(ranges[j])
+		return a.first.Less(b.first)
+	})
+	childRanges := []map[string]interface{}{}
+	for _, r := range ranges {
+		s, ok := rangeSpan(r)
+		if !ok || nested(s, children) {
+			continue
+		}
+		utilized, _ := r["mark_utilized"].(bool)
+		childRanges = append(childRanges, map[string]interface{}{
+			"id":            int(refID(r)),
+			"start_address": r["start_address"],
+			"end_address":   r["end_address"],
+			"size":          int(s.size().Int64()),
+			"mark_utilized": utilized,
+		})
+	}
+
+	depth, _ := prefix["_depth"].(float64)
+	d.SetId(parent)
+	for key, value := range map[string]interface{}{
+		"prefix_id":       int(refID(prefix)),
+		"prefix":          p.String(),
+		"size":            clampInt(size),
+		"used":            clampInt(usedSize),
+		"free":            clampInt(new(big.Int).Sub(size, usedSize)),
+		"utilization":     utilization,
+		"depth":           int(depth),
+		"child_prefixes":  childPrefixes,
+		"child_ip_ranges": childRanges,
+	} {
+		if err := d.Set(key, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	return nil
+}
+
+// clampInt returns n as an int, or the largest int if n does not fit, as for
+// the address counts of large IPv6 prefixes.
+func clampInt(n *big.Int) int {
+	if !n.IsInt64() || n.Int64() > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(n.Int64())
+}
+
+// within reports whether a prefix of prefixes shorter than bits holds a.
+func within(a netip.Addr, bits int, prefixes []netip.Prefix) bool {
+	for _, p := range prefixes {
+		if p.IsValid() && p.Bits() < bits && p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// nested reports whether a prefix of prefixes holds all of s.
+func nested(s span, prefixes []netip.Prefix) bool {
+	for _, p := range prefixes {
+		if p.IsValid() && p.Contains(s.first) && p.Contains(s.last) {
+			return true
+		}
+	}
+	return false
+}
+
+// span is the addresses from first to last, included.
+type span struct {
+	first, last netip.Addr
+}
+
+func prefixSpan(p netip.Prefix) span {
+	return span{p.Addr(), lastAddr(p)}
+}
+
+func rangeSpan(r map[string]interface{}) (span, bool) {
+	start, err1 := netip.ParsePrefix(fmt.Sprint(r["start_address"]))
+	end, err2 := netip.ParsePrefix(fmt.Sprint(r["end_address"]))
+	return span{start.Addr(), end.Addr()}, err1 == nil && err2 == nil
+}
+
+func (s span) size() *big.Int {
+	n := new(big.Int).Sub(addrInt(s.last), addrInt(s.first))
+	return n.Add(n, big.NewInt(1))
+}
+
+// unionSize returns the number of addresses in at least one of spans.
+func unionSize(spans []span) *big.Int {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].first.Less(spans[j].first) })
+	total := new(big.Int)
+	var cur *span
+	for i := range spans {
+		s := spans[i]
+		switch {
+		case cur == nil:
+			cur = &s
+		case s.first.Compare(cur.last) <= 0 || s.first == cur.last.Next():
+			if cur.last.Less(s.last) {
+				cur.last = s.last
+			}
+		default:
+			total.Add(total, cur.size())
+			cur = &s
+		}
+	}
+	if cur != nil {
+		total.Add(total, cur.size())
+	}
+	return total
+}
@@ -0,0 +1,155 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestAvailableLookups(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	vrf := netbox.Create("ipam/vrfs", map[string]interface{}{"name": "blue"})
+	global := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/24", "status": "active"})
+	netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/24", "vrf": vrf, "status": "active"})
+	netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/26", "status": "active"})
+	netbox.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.0.0.1/24", "status": "active"})
+	ipRange := netbox.Create("ipam/ip-ranges", map[string]interface{}{"start_address": "10.1.0.10/24", "end_address": "10.1.0.20/24", "status": "active"})
+	p := newTestProvider(t, netbox, nil)
+
+	for _, tc := range []struct {
+		name   string
+		tfName string
+		args   map[string]interface{}
+		key    resource.PropertyKey
+		want   []string
+	}{
+		{"addresses", "netbox_available_ips", map[string]interface{}{"prefixId": float64(global), "count": 2.0},
+			"ipAddresses", []string{"10.0.0.2/24", "10.0.0.3/24"}},
+		{"addresses in a VRF", "netbox_available_ips", map[string]interface{}{"prefix": "10.0.0.0/24", "vrfId": float64(vrf)},
+			"ipAddresses", []string{"10.0.0.1/24"}},
+		{"addresses of a range", "netbox_available_ips", map[string]interface{}{"ipRangeId": float64(ipRange)},
+			"ipAddresses", []string{"10.1.0.10/24"}},
+		{"blocks", "netbox_available_prefixes", map[string]interface{}{"prefix": "10.0.0.0/24", "count": 5.0},
+			"prefixes", []string{"10.0.0.64/26", "10.0.0.128/25"}},
+		{"candidates", "netbox_available_prefixes", map[string]interface{}{"prefixId": float64(global), "prefixLength": 27.0, "count": 3.0},
+			"prefixes", []string{"10.0.0.64/27", "10.0.0.96/27", "10.0.0.128/27"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := p.invoke(tc.tfName, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range out[tc.key].ArrayValue() {
+				got = append(got, v.StringValue())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s = %v, want %v", tc.key, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := p.invoke("netbox_available_prefixes", map[string]interface{}{"prefix": "10.9.0.0/24"}); err == nil || !strings.Contains(err.Error(), "no prefix 10.9.0.0/24 in the global table") {
+		t.Errorf("lookup of a missing prefix: got error %v", err)
+	}
+	if got := len(netbox.List("ipam/ip-addresses")) + len(netbox.List("ipam/prefixes")); got != 4 {
+		t.Errorf("lookups created objects: %d addresses and prefixes, want 4", got)
+	}
+}
+
+func TestPrefixUtilization(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	vrf := netbox.Create("ipam/vrfs", map[string]interface{}{"name": "blue"})
+	container := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/16", "status": "container"})
+	// NetBox stores the depth of each prefix, which the fake does not compute.
+	lan := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/24", "status": "active", "_depth": 1})
+	netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/26", "status": "reserved", "_depth": 2})
+	blue := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.1.0/24", "vrf": vrf, "status": "active"})
+	dhcp := netbox.Create("ipam/ip-ranges", map[string]interface{}{"start_address": "10.0.2.10/16", "end_address": "10.0.2.19/16", "status": "active"})
+	netbox.Create("ipam/ip-ranges", map[string]interface{}{"start_address": "10.0.0.100/24", "end_address": "10.0.0.109/24", "status": "active", "mark_utilized": true})
+	for _, address := range []string{"10.0.0.1/24", "10.0.0.2/24", "10.0.0.105/24"} {
+		netbox.Create("ipam/ip-addresses", map[string]interface{}{"address": address, "status": "active"})
+	}
+	netbox.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.0.0.3/24", "vrf": vrf, "status": "active"})
+	full := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/24", "vrf": vrf, "status": "active", "mark_utilized": true})
+	p := newTestProvider(t, netbox, nil)
+
+	out, err := p.invoke("netbox_prefix_utilization", map[string]interface{}{"prefixId": float64(container)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The container holds the /24 of the global table and the one of VRF blue.
+	if got := out["used"].NumberValue(); got != 512 {
+		t.Errorf("container used = %v, want 512", got)
+	}
+	if got := out["utilization"].NumberValue(); got != 512.0/65536*100 {
+		t.Errorf("container utilization = %v, want %v", got, 512.0/65536*100)
+	}
+	var children []float64
+	for _, child := range out["childPrefixes"].ArrayValue() {
+		children = append(children, child.ObjectValue()["id"].NumberValue())
+	}
+	if want := []float64{float64(lan), float64(full), float64(blue)}; !reflect.DeepEqual(children, want) {
+		t.Errorf("container children = %v, want %v", children, want)
+	}
+	if ranges := out["childIpRanges"].ArrayValue(); len(ranges) != 1 || ranges[0].ObjectValue()["id"].NumberValue() != float64(dhcp) {
+		t.Errorf("container ranges = %v, want [%d]", ranges, dhcp)
+	}
+
+	out, err = p.invoke("netbox_prefix_utilization", map[string]interface{}{"prefix": "10.0.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The range marked utilized counts 10 addresses, including 10.0.0.105,
+	// and the network and broadcast addresses are not usable.
+	for key, want := range map[resource.PropertyKey]float64{
+		"prefixId":    float64(lan),
+		"size":        254,
+		"used":        12,
+		"free":        242,
+		"utilization": 12.0 / 254 * 100,
+		"depth":       1,
+	} {
+		if got := out[key].NumberValue(); got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+
+	out, err = p.invoke("netbox_prefix_utilization", map[string]interface{}{"prefix": "10.0.0.0/24", "vrfId": float64(vrf)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out["utilization"].NumberValue(); got != 100 {
+		t.Errorf("utilization of a prefix marked utilized = %v, want 100", got)
+	}
+
+	v6 := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "2001:db8::/32", "status": "active"})
+	out, err = p.invoke("netbox_prefix_utilization", map[string]interface{}{"prefixId": float64(v6)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out["size"].NumberValue(); got != math.MaxInt64 {
+		t.Errorf("size of a /32 IPv6 prefix = %v, want the largest int64", got)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/e-breuninger/terraform-provider-netbox/netbox"
+	netboxclient "github.com/fbreckle/go-netbox/netbox/client"
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/goware/urlx"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/cassette"
+)
+
+// clientConfig extends the upstream client configuration with the options
+// this provider adds on top of it.
+type clientConfig struct {
+	netbox.Config
+
+	// RecordPath, when set, records every interaction to a cassette.
+	RecordPath string
+	// ReplayPath, when set, answers every request from a cassette instead of
+	// the server.
+	ReplayPath string
+	// DebugHTTP logs every request and response through the engine logger.
+	DebugHTTP bool
+	// DebugHTTPMaxBodySize truncates the bodies logged by DebugHTTP.
+	DebugHTTPMaxBodySize int
+	// ChangelogMessage is the template of the changelog message sent with
+	// every change, on NetBox versions that accept one.
+	ChangelogMessage string
+	// Journal, when set, adds a journal entry to the objects the provider
+	// creates or updates.
+	Journal *journalConfig
+	// Ownership, when set, marks the objects the provider manages with the
+	// owning stack.
+	Ownership *ownershipConfig
+	// Delete is what deleting a resource with a status does.
+	Delete *deleteConfig
+	// ReadOnly refuses every change to NetBox.
+	ReadOnly bool
+	// PermissionCheck is how the plan checks the token permissions.
+	PermissionCheck string
+
+	permissions permissionCache
+	tags        tagCache
+	plan        planRegistry
+	refused     refusedChanges
+	allocator   allocator
+
+	// httpClient and graphqlURL send GraphQL queries, which the REST
+	// client cannot, through the same transport.
+	httpClient *http.Client
+	graphqlURL string
+
+	correlation *correlationTransport
+}
+
+// Client builds the NetBox API client. It mirrors the upstream
+// netbox.Config.Client, but owns the HTTP transport so requests can be
+// logged, recorded or replayed.
+func (cfg *clientConfig) Client() (*netboxclient.NetBoxAPI, error) {
+	if cfg.APIToken == "" {
+		return nil, fmt.Errorf("missing netbox API key")
+	}
+
+	parsedURL, err := urlx.Parse(cfg.ServerURL)
+	if err != nil {
+		return nil, fmt.Errorf("error while trying to parse URL: %s", err)
+	}
+
+	trans, err := cfg.roundTripper()
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{
+		Transport: trans,
+		Timeout:   time.Second * time.Duration(cfg.RequestTimeout),
+	}
+
+	cfg.httpClient = httpClient
+	cfg.graphqlURL = fmt.Sprintf("%s://%s%s/graphql/", parsedURL.Scheme, parsedURL.Host, strings.TrimSuffix(parsedURL.Path, "/"))
+
+	transport := httptransport.NewWithClient(parsedURL.Host, parsedURL.Path+netboxclient.DefaultBasePath, []string{parsedURL.Scheme}, httpClient)
+	transport.DefaultAuthentication = httptransport.APIKeyAuth("Authorization", "header", fmt.Sprintf("Token %v", cfg.APIToken))
+	transport.SetLogger(log.StandardLogger())
+	return netboxclient.New(configTransport{ClientTransport: transport, config: cfg}, nil), nil
+}
+
+// configTransport keeps the provider configuration with the client it built.
+// Upstream resources expect the bare client as their meta, so this is how
+// operations find the options this provider adds.
+type configTransport struct {
+	runtime.ClientTransport
+	config *clientConfig
+}
+
+// configFrom returns the provider configuration the client was built from.
+func configFrom(meta interface{}) *clientConfig {
+	api, ok := meta.(*netboxclient.NetBoxAPI)
+	if !ok {
+		return &clientConfig{}
+	}
+	t := api.Transport
+	if ct, ok := t.(contextTransport); ok {
+		t = ct.ClientTransport
+	}
+	if ct, ok := t.(configTransport); ok {
+		return ct.config
+	}
+	return &clientConfig{}
+}
+
+func (cfg *clientConfig) roundTripper() (http.RoundTripper, error) {
+	headers := make([]string, 0, len(cfg.Headers))
+	for name := range cfg.Headers {
+		headers = append(headers, name)
+	}
+
+	var trans http.RoundTripper
+	if cfg.ReplayPath != "" {
+		replayer, err := cassette.NewReplayer(cfg.ReplayPath)
+		if err != nil {
+			return nil, err
+		}
+		trans = replayer
+	} else {
+		tls, err := httptransport.TLSTransport(httptransport.TLSClientOptions{
+			InsecureSkipVerify: cfg.AllowInsecureHTTPS,
+		})
+		if err != nil {
+			return nil, err
+		}
+		trans = tls
+		if cfg.RecordPath != "" {
+			recorder, err := cassette.NewRecorder(cfg.RecordPath, trans, cfg.APIToken)
+			if err != nil {
+				return nil, err
+			}
+			recorder.Headers = headers
+			trans = recorder
+		}
+	}
+
+	if cfg.ReadOnly {
+		trans = readOnlyTransport{next: trans}
+	}
+	if cfg.DebugHTTP {
+		trans = debugTransport{next: trans, headers: headers, maxBodySize: cfg.DebugHTTPMaxBodySize}
+	}
+	if len(cfg.Headers) > 0 {
+		trans = headerTransport{next: trans, headers: cfg.Headers}
+	}
+	if cfg.Ownership != nil {
+		trans = newOwnershipTransport(trans, cfg.Ownership, &cfg.tags)
+	}
+	trans = rejectionTransport{next: trans}
+	cfg.correlation = &correlationTransport{next: trans, message: cfg.ChangelogMessage}
+	return tracingTransport{next: cfg.correlation}, nil
+}
+
+// headerTransport adds the configured headers to every request.
+type headerTransport struct {
+	next    http.RoundTripper
+	headers map[string]interface{}
+}
+
+func (t headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	for key, value := range t.headers {
+		r.Header.Add(key, fmt.Sprintf("%v", value))
+	}
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,71 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "site.json")
+	inputs := resource.NewPropertyMapFromMap(map[string]interface{}{
+		"name":   "par1",
+		"slug":   "par1",
+		"status": "active",
+	})
+
+	run := func(p *testProvider) (string, resource.PropertyMap) {
+		urn := p.urn("netbox_site", "site")
+		checked := p.check(urn, nil, inputs)
+		id, state := p.create(urn, checked)
+		_, state, _ = p.read(urn, id, state, checked)
+		p.delete(urn, id, state)
+		return id, state
+	}
+
+	netbox := fakenetbox.NewServer()
+	recorded := newTestProvider(t, netbox, resource.PropertyMap{
+		"recordPath": resource.NewStringProperty(path),
+	})
+	wantID, wantState := run(recorded)
+	netbox.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "0123456789abcdef0123456789abcdef01234567") {
+		t.Errorf("cassette contains the API token")
+	}
+
+	// The fake is closed, so every response now has to come from the cassette.
+	replayed := newTestProvider(t, netbox, resource.PropertyMap{
+		"replayPath": resource.NewStringProperty(path),
+	})
+	id, state := run(replayed)
+	if id != wantID {
+		t.Errorf("replayed ID = %q, want %q", id, wantID)
+	}
+	if !state.DeepEquals(wantState) {
+		t.Errorf("replayed state = %v, want %v", state, wantState)
+	}
+}
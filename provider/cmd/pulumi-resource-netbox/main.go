@@ -19,7 +19,6 @@ package main
 import (
 	_ "embed"
 
-	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
 	netbox "github.com/SpikeeLabs/pulumi-netbox/provider"
 	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/version"
 )
@@ -29,5 +28,5 @@ var pulumiSchema []byte
 
 func main() {
 	// Modify the path to point to the new provider
-	tfbridge.Main("netbox", version.Version, netbox.Provider(), pulumiSchema)
+	netbox.Main("netbox", version.Version, netbox.Provider(), pulumiSchema)
 }
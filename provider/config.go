@@ -0,0 +1,194 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/e-breuninger/terraform-provider-netbox/netbox"
+	"github.com/fbreckle/go-netbox/netbox/client/status"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// supportedVersions are the NetBox versions the upstream provider is tested
+// against. Keep in sync with its providerConfigure.
+var supportedVersions = []string{"3.7.0", "3.7.1", "3.7.2", "3.7.3", "3.7.4"}
+
+// netboxProvider returns the upstream Terraform provider extended with the
+// configuration this package adds, and configured by configure.
+func netboxProvider() *schema.Provider {
+	p := netbox.Provider()
+
+	p.Schema["record_path"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_RECORD", ""),
+		Description: "Record every NetBox HTTP interaction to this cassette file, with credentials redacted. Can be set via the `NETBOX_RECORD` environment variable.",
+	}
+	p.Schema["replay_path"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_REPLAY", ""),
+		Description: "Serve every NetBox HTTP request from this cassette file instead of the server. Can be set via the `NETBOX_REPLAY` environment variable.",
+	}
+
+	p.Schema["debug_http"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_DEBUG_HTTP", false),
+		Description: "Log every NetBox HTTP request and response at debug level, with credentials redacted. Can be set via the `NETBOX_DEBUG_HTTP` environment variable. Defaults to `false`.",
+	}
+	p.Schema["debug_http_max_body_size"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_DEBUG_HTTP_MAX_BODY_SIZE", 4096),
+		Description: "Truncate bodies logged by `debug_http` to this many bytes, or `0` to log them in full. Can be set via the `NETBOX_DEBUG_HTTP_MAX_BODY_SIZE` environment variable. Defaults to `4096`.",
+	}
+
+	p.Schema["changelog_message"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_CHANGELOG_MESSAGE", defaultChangelogMessage),
+		Description: "The changelog message recorded with every change, on NetBox 4.4 and later. `{kind}`, `{urn}`, `{stack}`, `{project}`, `{type}`, `{name}` and `{requestId}` are replaced with the details of the operation. An empty message disables changelog messages. With `skip_version_check` the message is sent to any NetBox version, and ignored before 4.4. Can be set via the `NETBOX_CHANGELOG_MESSAGE` environment variable. Defaults to `" + defaultChangelogMessage + "`.",
+	}
+
+	p.Schema["journal_changes"] = journalChangesSchema()
+	p.Schema["ownership"] = ownershipSchema()
+
+	p.Schema["read_only"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_READ_ONLY", false),
+		Description: "Refuse to create, update or delete anything, failing the plan of every resource that would change. Reads, refreshes and functions work as usual. Can be set via the `NETBOX_READ_ONLY` environment variable. Defaults to `false`.",
+	}
+
+	p.Schema["permission_check"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		DefaultFunc:  schema.EnvDefaultFunc("NETBOX_PERMISSION_CHECK", permissionCheckOff),
+		ValidateFunc: validation.StringInSlice(permissionCheckModes, false),
+		Description:  "Check during the plan that the object permissions of the API token allow each planned create, update and replace, and each delete before it is applied, needing no permission, `change` or `delete` as the delete behavior retains, retires or deletes the object: `off`, `warn` or `error`. Superusers hold every permission. Can be set via the `NETBOX_PERMISSION_CHECK` environment variable. Defaults to `off`.",
+	}
+
+	p.Schema["delete_behavior"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		DefaultFunc:  schema.EnvDefaultFunc("NETBOX_DELETE_BEHAVIOR", deleteObject),
+		ValidateFunc: validation.StringInSlice(deleteBehaviors, false),
+		Description:  "What deleting a resource with a status does to its NetBox object: `delete` it, `retain` it untouched, or set its `status` to `delete_status`. Resources can override it with their own `delete_behavior`. Can be set via the `NETBOX_DELETE_BEHAVIOR` environment variable. Defaults to `delete`.",
+	}
+	p.Schema["delete_status"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_DELETE_STATUS", ""),
+		Description: "The status set by the `status` delete behaviour. Defaults to a retired status valid for each type, such as `decommissioning` for devices and `deprecated` for prefixes. Can be set via the `NETBOX_DELETE_STATUS` environment variable.",
+	}
+	p.Schema["delete_tag"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		DefaultFunc: schema.EnvDefaultFunc("NETBOX_DELETE_TAG", ""),
+		Description: "The name of a tag added to objects retired by the `status` delete behaviour. The tag is created when missing. Can be set via the `NETBOX_DELETE_TAG` environment variable.",
+	}
+
+	p.ResourcesMap["netbox_available_asn"] = resourceAvailableASN()
+	p.ResourcesMap["netbox_available_vlan"] = resourceAvailableVLAN()
+	p.ResourcesMap["netbox_object"] = resourceGenericObject()
+	p.DataSourcesMap["netbox_available_ips"] = dataSourceAvailableIPs()
+	p.DataSourcesMap["netbox_available_prefixes"] = dataSourceAvailablePrefixes()
+	p.DataSourcesMap["netbox_graphql_query"] = dataSourceGraphQLQuery()
+	p.DataSourcesMap["netbox_objects"] = dataSourceGenericObjects()
+	p.DataSourcesMap["netbox_prefix_utilization"] = dataSourcePrefixUtilization()
+
+	p.ConfigureContextFunc = configure
+	wrapOperations(p)
+	return p
+}
+
+// configure builds the NetBox client. It follows the upstream
+// providerConfigure, using clientConfig so the provider owns the transport.
+func configure(ctx context.Context, data *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	config := clientConfig{
+		Config: netbox.Config{
+			APIToken:                    data.Get("api_token").(string),
+			AllowInsecureHTTPS:          data.Get("allow_insecure_https").(bool),
+			Headers:                     data.Get("headers").(map[string]interface{}),
+			RequestTimeout:              data.Get("request_timeout").(int),
+			StripTrailingSlashesFromURL: data.Get("strip_trailing_slashes_from_url").(bool),
+		},
+		RecordPath:           data.Get("record_path").(string),
+		ReplayPath:           data.Get("replay_path").(string),
+		DebugHTTP:            data.Get("debug_http").(bool),
+		DebugHTTPMaxBodySize: data.Get("debug_http_max_body_size").(int),
+		ChangelogMessage:     data.Get("changelog_message").(string),
+		Journal:              readJournalConfig(data),
+		Ownership:            readOwnershipConfig(data),
+		ReadOnly:             data.Get("read_only").(bool),
+		PermissionCheck:      data.Get("permission_check").(string),
+		Delete: &deleteConfig{
+			Behavior: data.Get("delete_behavior").(string),
+			Status:   data.Get("delete_status").(string),
+			Tag:      data.Get("delete_tag").(string),
+		},
+	}
+	if config.RecordPath != "" && config.ReplayPath != "" {
+		return nil, diag.Errorf("record_path and replay_path cannot be used together")
+	}
+
+	serverURL := data.Get("server_url").(string)
+	if config.StripTrailingSlashesFromURL && strings.HasSuffix(serverURL, "/") {
+		serverURL = strings.TrimRight(serverURL, "/")
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Stripped trailing slashes from the `server_url` parameter",
+			Detail:   "Trailing slashes in the `server_url` parameter lead to problems in most setups, so all trailing slashes were stripped. Use the `strip_trailing_slashes_from_url` parameter to disable this feature or remove all trailing slashes in the `server_url` to disable this warning.",
+		})
+	}
+	config.ServerURL = serverURL
+
+	client, err := config.Client()
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	// Without the version check the server is not known to record changelog
+	// messages, but older servers ignore them, so they are sent anyway.
+	config.correlation.changelog = true
+	if !data.Get("skip_version_check").(bool) {
+		res, err := client.Status.StatusList(status.NewStatusListParams().WithContext(ctx), nil)
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+
+		version, _ := res.GetPayload().(map[string]interface{})["netbox-version"].(string)
+		if !slices.Contains(supportedVersions, version) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Possibly unsupported Netbox version",
+				Detail:   fmt.Sprintf("Your Netbox version is v%v. The provider was successfully tested against the following versions:\n\n  %v\n\nUnexpected errors may occur.", version, strings.Join(supportedVersions, ", ")),
+			})
+		}
+		// Only send changelog messages to servers known to record them.
+		config.correlation.changelog = versionAtLeast(version, changelogMessageVersion)
+	}
+
+	return client, diags
+}
@@ -0,0 +1,420 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// nonUniqueRoles are the IP address roles NetBox lets addresses share.
+var nonUniqueRoles = map[string]bool{
+	"anycast": true,
+	"carp":    true,
+	"glbp":    true,
+	"hsrp":    true,
+	"vip":     true,
+	"vrrp":    true,
+}
+
+// conflictChecks find the planned resources colliding with NetBox objects or
+// with other resources of the plan.
+var conflictChecks = map[string]schema.CustomizeDiffFunc{
+	"netbox_available_ip_address": checkAllocationConflicts,
+	"netbox_device":               checkRackConflicts,
+	"netbox_ip_address":           checkAddressConflicts,
+}
+
+// placement is the rack space a device takes.
+type placement struct {
+	// owner is the resource placing the device, and id the device, if it
+	// exists.
+	owner string
+	id    string
+	rack  int
+	// The device takes the units from start up to end, excluded.
+	start, end float64
+	// face is the rack face, or empty for both.
+	face string
+}
+
+func (p placement) overlaps(o placement) bool {
+	return p.rack == o.rack && p.start < o.end && o.start < p.end &&
+		(p.face == "" || o.face == "" || p.face == o.face)
+}
+
+func (p placement) units() string {
+	if p.end-p.start <= 1 {
+		return fmt.Sprintf("U%v", p.start)
+	}
+	return fmt.Sprintf("U%v-U%v", p.start, p.end-1)
+}
+
+// addressClaim is an IP address a resource assigns.
+type addressClaim struct {
+	owner string
+	id    string
+	// key is the VRF and host of the address.
+	key string
+}
+
+// allocationClaim is a number of addresses a resource allocates from a
+// parent prefix or IP range.
+type allocationClaim struct {
+	owner string
+	// parent is the path listing the free addresses of the parent.
+	parent string
+	count  int
+}
+
+// planRegistry holds the rack space, IP addresses and allocations the
+// resources planned so far by the provider claim, by URN, so resources of the
+// same plan are checked against each other. It belongs to the configured
+// provider, which the engine starts for each deployment, and the entry of a
+// resource is replaced, or dropped, each time the resource is planned again.
+type planRegistry struct {
+	mu          sync.Mutex
+	placements  map[string]placement
+	addresses   map[string]addressClaim
+	allocations map[string]allocationClaim
+}
+
+// forget drops what owner claimed in an earlier plan.
+func (r *planRegistry) forget(owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.placements, owner)
+	delete(r.addresses, owner)
+	delete(r.allocations, owner)
+}
+
+// release drops the allocation claimed by owner, once it has been made. The
+// allocated addresses are then no longer free in NetBox, and would otherwise
+// be counted twice against the allocations planned after it.
+func (r *planRegistry) release(owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.allocations, owner)
+}
+
+// place records p, and returns the placements of the other resources it
+// overlaps, by URN.
+func (r *planRegistry) place(p placement) []placement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.placements == nil {
+		r.placements = map[string]placement{}
+	}
+	r.placements[p.owner] = p
+	var overlapping []placement
+	for owner, o := range r.placements {
+		if owner != p.owner && o.overlaps(p) {
+			overlapping = append(overlapping, o)
+		}
+	}
+	sort.Slice(overlapping, func(i, j int) bool { return overlapping[i].owner < overlapping[j].owner })
+	return overlapping
+}
+
+// assign records c, and returns the URNs of the other resources claiming the
+// same address, in order.
+func (r *planRegistry) assign(c addressClaim) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.addresses == nil {
+		r.addresses = map[string]addressClaim{}
+	}
+	r.addresses[c.owner] = c
+	var owners []string
+	for owner, o := range r.addresses {
+		if owner != c.owner && o.key == c.key {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// allocate records c, and returns every claim on its parent, including c, by
+// URN.
+func (r *planRegistry) allocate(c allocationClaim) []allocationClaim {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.allocations == nil {
+		r.allocations = map[string]allocationClaim{}
+	}
+	r.allocations[c.owner] = c
+	var claims []allocationClaim
+	for _, o := range r.allocations {
+		if o.parent == c.parent {
+			claims = append(claims, o)
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].owner < claims[j].owner })
+	return claims
+}
+
+// planned reports whether a resource of the plan, other than owner, changes
+// the object id, in which case its planned state supersedes the live one.
+func (r *planRegistry) planned(owner, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for o, p := range r.placements {
+		if o != owner && p.id == id {
+			return true
+		}
+	}
+	for o, c := range r.addresses {
+		if o != owner && c.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// joinOwners lists resources as "a, b and c".
+func joinOwners(owners []string) string {
+	if len(owners) < 2 {
+		return strings.Join(owners, "")
+	}
+	return strings.Join(owners[:len(owners)-1], ", ") + " and " + owners[len(owners)-1]
+}
+
+// conflictResource fails the plan of r when it would take rack space or an IP
+// address already used in NetBox or by another resource of the plan. Clashes
+// between resources of the plan are reported alike whichever is planned
+// first. Allocations are released once created.
+func conflictResource(name string, r *schema.Resource) {
+	check, ok := conflictChecks[name]
+	if !ok {
+		return
+	}
+	customize := r.CustomizeDiff
+	r.CustomizeDiff = func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		configFrom(meta).plan.forget(describeResource(ctx, ""))
+		if err := check(ctx, d, meta); err != nil {
+			return err
+		}
+		if customize == nil {
+			return nil
+		}
+		return customize(ctx, d, meta)
+	}
+
+	create := r.CreateContext
+	r.CreateContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		defer configFrom(meta).plan.release(describeResource(ctx, ""))
+		return create(ctx, d, meta)
+	}
+}
+
+// deviceType is the rack space taken by the devices of a type.
+type deviceType struct {
+	height    float64
+	fullDepth bool
+}
+
+func readDeviceType(ctx context.Context, meta interface{}, id int64) (deviceType, error) {
+	res, err := apiCall(ctx, meta, http.MethodGet, fmt.Sprintf("dcim/device-types/%d/", id), nil, nil)
+	if err != nil {
+		return deviceType{}, err
+	}
+	obj, _ := res.(map[string]interface{})
+	typ := deviceType{height: 1}
+	if h, ok := obj["u_height"].(float64); ok {
+		typ.height = h
+	}
+	typ.fullDepth, _ = obj["is_full_depth"].(bool)
+	return typ, nil
+}
+
+func checkRackConflicts(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChanges("rack_id", "rack_position", "rack_face", "device_type_id") {
+		return nil
+	}
+	values, ok := knownValues(d, "rack_id", "rack_position")
+	if !ok {
+		return nil
+	}
+	owner := describeResource(ctx, "")
+	planned := placement{owner: owner, id: d.Id(), rack: values[0].(int), start: values[1].(float64)}
+	typ := deviceType{height: 1}
+	if values, ok := knownValues(d, "device_type_id"); ok {
+		if t, err := readDeviceType(ctx, meta, int64(values[0].(int))); err == nil {
+			typ = t
+		}
+	}
+	if typ.height == 0 {
+		return nil
+	}
+	planned.end = planned.start + typ.height
+	if face, ok := knownValues(d, "rack_face"); ok && !typ.fullDepth {
+		planned.face, _ = face[0].(string)
+	}
+
+	cfg := configFrom(meta)
+	if others := cfg.plan.place(planned); len(others) > 0 && owner != "" {
+		placed := []placement{planned}
+		placed = append(placed, others...)
+		sort.Slice(placed, func(i, j int) bool { return placed[i].owner < placed[j].owner })
+		described := make([]string, len(placed))
+		for i, p := range placed {
+			described[i] = p.owner + " at " + p.units()
+		}
+		return fmt.Errorf("%s are planned in overlapping units of rack %d", joinOwners(described), planned.rack)
+	}
+
+	devices, err := listObjects(ctx, meta, "dcim/devices/", url.Values{"rack_id": {strconv.Itoa(planned.rack)}}, 0)
+	if err != nil {
+		return nil
+	}
+	types := map[int64]deviceType{}
+	for _, dev := range devices {
+		id := strconv.FormatInt(refID(dev), 10)
+		position, ok := dev["position"].(float64)
+		if !ok || id == d.Id() || cfg.plan.planned(owner, id) {
+			continue
+		}
+		typeID := refID(dev["device_type"])
+		t, ok := types[typeID]
+		if !ok {
+			if t, err = readDeviceType(ctx, meta, typeID); err != nil {
+				continue
+			}
+			types[typeID] = t
+		}
+		live := placement{id: id, rack: planned.rack, start: position, end: position + t.height}
+		if face, _ := dev["face"].(map[string]interface{}); face != nil && !t.fullDepth {
+			live.face, _ = face["value"].(string)
+		}
+		if t.height > 0 && live.overlaps(planned) {
+			return fmt.Errorf("%s conflicts with device %v (%s), which takes %s of rack %d", owner, dev["name"], id, live.units(), live.rack)
+		}
+	}
+	return nil
+}
+
+func checkAddressConflicts(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChanges("ip_address", "vrf_id", "role") {
+		return nil
+	}
+	if role, ok := knownValues(d, "role"); ok && nonUniqueRoles[role[0].(string)] {
+		return nil
+	}
+	values, ok := knownValues(d, "ip_address")
+	if !ok || !d.NewValueKnown("vrf_id") {
+		return nil
+	}
+	address, err := netip.ParsePrefix(values[0].(string))
+	if err != nil {
+		return nil
+	}
+	host := address.Addr().String()
+	vrf, table := "null", "the global table"
+	if id, _ := d.Get("vrf_id").(int); id != 0 {
+		vrf, table = strconv.Itoa(id), "VRF "+strconv.Itoa(id)
+	}
+
+	owner := describeResource(ctx, "")
+	cfg := configFrom(meta)
+	if others := cfg.plan.assign(addressClaim{owner: owner, id: d.Id(), key: vrf + "/" + host}); len(others) > 0 && owner != "" {
+		owners := append([]string{owner}, others...)
+		sort.Strings(owners)
+		return fmt.Errorf("%s all plan to assign %s in %s", joinOwners(owners), host, table)
+	}
+
+	res, err := apiCall(ctx, meta, http.MethodGet, "ipam/ip-addresses/", url.Values{
+		"address": {host},
+		"vrf_id":  {vrf},
+	}, nil)
+	if err != nil {
+		return nil
+	}
+	results, _ := res.(map[string]interface{})["results"].([]interface{})
+	for _, item := range results {
+		ip, _ := item.(map[string]interface{})
+		id := strconv.FormatInt(refID(ip), 10)
+		if id == d.Id() || cfg.plan.planned(owner, id) {
+			continue
+		}
+		if role, _ := ip["role"].(map[string]interface{}); role != nil {
+			if value, _ := role["value"].(string); nonUniqueRoles[value] {
+				continue
+			}
+		}
+		return fmt.Errorf("%s conflicts with IP address %v (%s) in %s", owner, ip["address"], id, table)
+	}
+	return nil
+}
+
+// checkAllocationConflicts checks that the parent of a planned address
+// allocation has enough free addresses for it and for the other allocations
+// of the plan from the same parent. The address is only known once
+// allocated, so existing allocations are checked like IP addresses.
+func checkAllocationConflicts(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() != "" {
+		return checkAddressConflicts(ctx, d, meta)
+	}
+	var claim allocationClaim
+	var parent string
+	if values, ok := knownValues(d, "prefix_id"); ok {
+		claim.parent = fmt.Sprintf("ipam/prefixes/%d/available-ips/", values[0].(int))
+		parent = fmt.Sprintf("prefix %d", values[0].(int))
+	} else if values, ok := knownValues(d, "ip_range_id"); ok {
+		claim.parent = fmt.Sprintf("ipam/ip-ranges/%d/available-ips/", values[0].(int))
+		parent = fmt.Sprintf("IP range %d", values[0].(int))
+	} else {
+		return nil
+	}
+	claim.count = 1
+	if count, ok := d.GetOk("allocation_count"); ok {
+		if !d.NewValueKnown("allocation_count") {
+			return nil
+		}
+		claim.count = count.(int)
+	}
+	claim.owner = describeResource(ctx, "")
+
+	claims := configFrom(meta).plan.allocate(claim)
+	total := 0
+	owners := make([]string, len(claims))
+	for i, c := range claims {
+		total += c.count
+		owners[i] = c.owner
+	}
+	res, err := apiCall(ctx, meta, http.MethodGet, claim.parent, url.Values{"limit": {strconv.Itoa(total)}}, nil)
+	if err != nil {
+		return nil
+	}
+	free, _ := res.([]interface{})
+	if len(free) >= total {
+		return nil
+	}
+	verb := "plans"
+	if len(owners) > 1 {
+		verb = "plan"
+	}
+	return fmt.Errorf("%s %s to allocate %d addresses from %s, which has %d free", joinOwners(owners), verb, total, parent, len(free))
+}
@@ -0,0 +1,201 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestPlanConflicts(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	ids := fixtures(netbox)
+	tall := netbox.Create("dcim/device-types", map[string]interface{}{
+		"model": "chassis", "slug": "chassis", "manufacturer": ids["manufacturer"], "u_height": 4,
+	})
+	spine := netbox.Create("dcim/devices", map[string]interface{}{
+		"name": "spine1", "site": ids["site"], "device_type": tall, "role": ids["deviceRole"], "status": "active",
+		"rack": ids["rack"], "position": 10, "face": "front",
+	})
+	p := newTestProvider(t, netbox, nil)
+
+	device := func(name string, position float64, face string) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name, "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]),
+			"siteId": float64(ids["site"]), "rackId": float64(ids["rack"]), "rackPosition": position, "rackFace": face,
+		}
+	}
+	address := func(ip, role string) map[string]interface{} {
+		inputs := map[string]interface{}{"ipAddress": ip, "status": "active"}
+		if role != "" {
+			inputs["role"] = role
+		}
+		return inputs
+	}
+	tests := []struct {
+		tfName, name string
+		inputs       map[string]interface{}
+		// err is part of the expected error, if any.
+		err string
+	}{
+		{"netbox_device", "leaf2", device("leaf2", 12, "front"), fmt.Sprintf("device spine1 (%d), which takes U10-U13 of rack %d", spine, ids["rack"])},
+		{"netbox_device", "leaf2", device("leaf2", 12, "rear"), ""},
+		{"netbox_device", "leaf3", device("leaf3", 14, "front"), ""},
+		// leaf4 collides with leaf3, planned above.
+		{"netbox_device", "leaf4", device("leaf4", 14, "front"), fmt.Sprintf("Device::leaf3 at U14 and urn:pulumi:test::netbox::netbox:index/device:Device::leaf4 at U14 are planned in overlapping units of rack %d", ids["rack"])},
+		// leaf3 moves away, so its earlier plan no longer holds U14.
+		{"netbox_device", "leaf3", device("leaf3", 20, "front"), ""},
+		{"netbox_device", "leaf4", device("leaf4", 14, "front"), ""},
+		{"netbox_ip_address", "a", address("192.168.0.1/32", ""), fmt.Sprintf("IP address 192.168.0.1/24 (%d) in the global table", ids["ipAddress"])},
+		{"netbox_ip_address", "a", address("192.168.0.1/24", "vip"), ""},
+		{"netbox_ip_address", "b", address("10.0.0.5/24", ""), ""},
+		{"netbox_ip_address", "c", address("10.0.0.5/16", ""), "IpAddress::b and urn:pulumi:test::netbox::netbox:index/ipAddress:IpAddress::c all plan to assign 10.0.0.5 in the global table"},
+	}
+	for _, tt := range tests {
+		urn := p.urn(tt.tfName, tt.name)
+		_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+			Urn:        string(urn),
+			Properties: p.marshal(p.check(urn, nil, resource.NewPropertyMapFromMap(tt.inputs))),
+			Preview:    true,
+		})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s %s: %v", tt.tfName, tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s %s: got error %v, want %q", tt.tfName, tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestPlanConflictsOrder(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	ids := fixtures(netbox)
+
+	// The clash is reported alike whichever device is planned first.
+	var errs []string
+	for _, names := range [][]string{{"leaf1", "leaf2"}, {"leaf2", "leaf1"}} {
+		p := newTestProvider(t, netbox, nil)
+		var err error
+		for _, name := range names {
+			urn := p.urn("netbox_device", name)
+			_, err = p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+				Urn: string(urn), Preview: true,
+				Properties: p.marshal(p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+					"name": name, "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]),
+					"siteId": float64(ids["site"]), "rackId": float64(ids["rack"]), "rackPosition": 30.0, "rackFace": "front",
+				}))),
+			})
+		}
+		if err == nil {
+			t.Fatalf("planning %v: no conflict", names)
+		}
+		_, msg, _ := strings.Cut(err.Error(), ": ")
+		errs = append(errs, msg)
+	}
+	if errs[0] != errs[1] {
+		t.Errorf("conflict depends on the plan order:\n%s\n%s", errs[0], errs[1])
+	}
+}
+
+func TestPlanConflictsPaging(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	ids := fixtures(netbox)
+	for i := 0; i < 1000; i++ {
+		netbox.Create("dcim/devices", map[string]interface{}{
+			"name": fmt.Sprintf("unracked%d", i), "site": ids["site"], "device_type": ids["deviceType"], "role": ids["deviceRole"],
+			"status": "active", "rack": ids["rack"],
+		})
+	}
+	// The device taking U40 is on the second page of the rack's devices.
+	netbox.Create("dcim/devices", map[string]interface{}{
+		"name": "spine9", "site": ids["site"], "device_type": ids["deviceType"], "role": ids["deviceRole"],
+		"status": "active", "rack": ids["rack"], "position": 40, "face": "front",
+	})
+
+	p := newTestProvider(t, netbox, nil)
+	urn := p.urn("netbox_device", "leaf9")
+	_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn: string(urn), Preview: true,
+		Properties: p.marshal(p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+			"name": "leaf9", "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]),
+			"siteId": float64(ids["site"]), "rackId": float64(ids["rack"]), "rackPosition": 40.0, "rackFace": "front",
+		}))),
+	})
+	if err == nil || !strings.Contains(err.Error(), "conflicts with device spine9") {
+		t.Errorf("device on the second page: got error %v", err)
+	}
+}
+
+func TestPlanAllocationConflicts(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	prefix := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.9.0.0/29", "status": "active", "is_pool": true})
+	p := newTestProvider(t, netbox, nil)
+
+	preview := func(name string, count float64) error {
+		urn := p.urn("netbox_available_ip_address", name)
+		_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+			Urn: string(urn), Preview: true,
+			Properties: p.marshal(p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+				"prefixId": float64(prefix), "count": count,
+			}))),
+		})
+		return err
+	}
+	if err := preview("a", 5); err != nil {
+		t.Errorf("allocation within the free addresses: %v", err)
+	}
+	want := fmt.Sprintf("AvailableIpAddress::a and urn:pulumi:test::netbox::netbox:index/availableIpAddress:AvailableIpAddress::b plan to allocate 9 addresses from prefix %d, which has 8 free", prefix)
+	if err := preview("b", 4); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("allocations beyond the free addresses: got error %v, want %q", err, want)
+	}
+	if err := preview("b", 3); err != nil {
+		t.Errorf("allocations within the free addresses: %v", err)
+	}
+}
+
+func TestPlanAllocationConflictsApplied(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	prefix := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.9.0.0/29", "status": "active", "is_pool": true})
+	p := newTestProvider(t, netbox, nil)
+
+	// Once a is allocated, its addresses are no longer free in NetBox, so
+	// its claim must not count against b.
+	for _, alloc := range []struct {
+		name  string
+		count float64
+	}{{"a", 5}, {"b", 3}} {
+		urn := p.urn("netbox_available_ip_address", alloc.name)
+		if _, _, err := p.tryCreate(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+			"prefixId": float64(prefix), "count": alloc.count,
+		}))); err != nil {
+			t.Errorf("allocation of %s: %v", alloc.name, err)
+		}
+	}
+	if addresses := netbox.List("ipam/ip-addresses"); len(addresses) != 8 {
+		t.Errorf("allocated %d addresses, want 8", len(addresses))
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/cassette"
+	"github.com/google/uuid"
+)
+
+// defaultChangelogMessage is the changelog message written with every change
+// the provider makes, unless configured otherwise.
+const defaultChangelogMessage = "Pulumi {kind} of {urn} in stack {stack}"
+
+// changelogMessageVersion is the first NetBox release accepting a
+// changelog_message with write requests.
+const changelogMessageVersion = "4.4"
+
+// correlationTransport tags every request with the operation that made it, so
+// NetBox logs and changelog entries can be traced back to a Pulumi update.
+type correlationTransport struct {
+	next http.RoundTripper
+	// message is the changelog message template. Empty disables changelog
+	// messages.
+	message string
+	// changelog is set once the server is known to accept changelog messages.
+	changelog bool
+}
+
+func (t *correlationTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	op, ok := operationFrom(r.Context())
+	r = r.Clone(r.Context())
+	if ok {
+		r.Header.Set("X-Request-ID", op.RequestID)
+	} else {
+		r.Header.Set("X-Request-ID", uuid.NewString())
+	}
+
+	if ok && op.URN != "" && t.changelog && t.message != "" && isWrite(r.Method) {
+		body, err := cassette.ReadBody(&r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if body, ok := withChangelogMessage(body, op.changelogMessage(t.message)); ok {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Type", "application/json")
+		}
+	}
+	return t.next.RoundTrip(r)
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// changelogMessage renders the changelog message template for the operation.
+func (op *operation) changelogMessage(template string) string {
+	kind := strings.ToLower(op.Kind)
+	return strings.NewReplacer(
+		"{kind}", kind,
+		"{urn}", string(op.URN),
+		"{stack}", string(op.URN.Stack()),
+		"{project}", string(op.URN.Project()),
+		"{type}", string(op.URN.Type()),
+		"{name}", op.URN.Name(),
+		"{requestId}", op.RequestID,
+	).Replace(template)
+}
+
+// withChangelogMessage adds the message to a JSON request body: to the object
+// itself, or to each object of a bulk request. An empty body becomes an object
+// holding only the message, as NetBox expects for deletes.
+func withChangelogMessage(body []byte, message string) ([]byte, bool) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, false
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		v["changelog_message"] = message
+	case []interface{}:
+		for _, item := range v {
+			if obj, ok := item.(map[string]interface{}); ok {
+				obj["changelog_message"] = message
+			}
+		}
+	default:
+		return nil, false
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// versionAtLeast reports whether the NetBox version is min or later, comparing
+// the numeric release components only.
+func versionAtLeast(version, min string) bool {
+	v, m := versionParts(version), versionParts(min)
+	for i := range m {
+		if i >= len(v) {
+			return false
+		}
+		if v[i] != m[i] {
+			return v[i] > m[i]
+		}
+	}
+	return true
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+ "); i >= 0 {
+		version = version[:i]
+	}
+	var parts []int
+	for _, s := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,152 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+type capturedRequest struct {
+	method, path, requestID string
+	body                    map[string]interface{}
+}
+
+// captureRequests records every API request the fake receives.
+func captureRequests(s *fakenetbox.Server) func() []capturedRequest {
+	var mu sync.Mutex
+	var captured []capturedRequest
+	s.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		b, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		c := capturedRequest{method: r.Method, path: r.URL.Path, requestID: r.Header.Get("X-Request-ID")}
+		json.Unmarshal(b, &c.body)
+		mu.Lock()
+		defer mu.Unlock()
+		captured = append(captured, c)
+		return false
+	})
+	return func() []capturedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]capturedRequest(nil), captured...)
+	}
+}
+
+func TestChangelogMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		config  resource.PropertyMap
+		create  interface{}
+		delete  interface{}
+	}{
+		{
+			name:    "default",
+			version: "4.4.1",
+			create:  "Pulumi create of urn:pulumi:test::netbox::netbox:index/site:Site::par1 in stack test",
+			delete:  "Pulumi delete of urn:pulumi:test::netbox::netbox:index/site:Site::par1 in stack test",
+		},
+		{
+			name:    "custom",
+			version: "4.4.0",
+			config:  resource.PropertyMap{"changelogMessage": resource.NewStringProperty("{project}/{stack}: {kind} {name}")},
+			create:  "netbox/test: create par1",
+			delete:  "netbox/test: delete par1",
+		},
+		{
+			name:    "disabled",
+			version: "4.4.0",
+			config:  resource.PropertyMap{"changelogMessage": resource.NewStringProperty("")},
+		},
+		{
+			name:    "unsupported version",
+			version: fakenetbox.DefaultVersion,
+		},
+		{
+			name:    "version check skipped",
+			version: fakenetbox.DefaultVersion,
+			config:  resource.PropertyMap{"skipVersionCheck": resource.NewBoolProperty(true)},
+			create:  "Pulumi create of urn:pulumi:test::netbox::netbox:index/site:Site::par1 in stack test",
+			delete:  "Pulumi delete of urn:pulumi:test::netbox::netbox:index/site:Site::par1 in stack test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netbox := fakenetbox.NewServer()
+			defer netbox.Close()
+			netbox.Version = tt.version
+			requests := captureRequests(netbox)
+
+			p := newTestProvider(t, netbox, tt.config)
+			urn := p.urn("netbox_site", "par1")
+			inputs := p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})
+			id, state := p.create(urn, inputs)
+			p.delete(urn, id, state)
+
+			messages := map[string]interface{}{}
+			ids := map[string]string{}
+			for _, r := range requests() {
+				if r.requestID == "" {
+					t.Errorf("%s %s has no X-Request-ID", r.method, r.path)
+				}
+				if r.method == http.MethodPost || r.method == http.MethodDelete {
+					messages[r.method] = r.body["changelog_message"]
+					if prev, ok := ids[r.method]; ok && prev != r.requestID {
+						t.Errorf("requests of one %s operation have different IDs", r.method)
+					}
+					ids[r.method] = r.requestID
+				}
+			}
+			if ids[http.MethodPost] == ids[http.MethodDelete] {
+				t.Errorf("create and delete share request ID %q", ids[http.MethodPost])
+			}
+			if messages[http.MethodPost] != tt.create {
+				t.Errorf("create changelog message %v, want %v", messages[http.MethodPost], tt.create)
+			}
+			if messages[http.MethodDelete] != tt.delete {
+				t.Errorf("delete changelog message %v, want %v", messages[http.MethodDelete], tt.delete)
+			}
+		})
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		want    bool
+	}{
+		{"4.4", true},
+		{"4.4.0", true},
+		{"v4.4.2-Docker-3.0.2", true},
+		{"4.10.1", true},
+		{"5.0.0", true},
+		{"4.3.7", false},
+		{"3.7.4", false},
+		{"", false},
+	} {
+		if got := versionAtLeast(tt.version, "4.4"); got != tt.want {
+			t.Errorf("versionAtLeast(%q, 4.4) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
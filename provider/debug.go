@@ -0,0 +1,127 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	"github.com/pulumi/pulumi-terraform-bridge/v3/unstable/logging"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/cassette"
+)
+
+const redacted = cassette.Redacted
+
+// debugTransport logs every request and response through the engine logger,
+// with credentials redacted.
+type debugTransport struct {
+	next http.RoundTripper
+	// headers are the names of headers whose values are redacted, in
+	// addition to Authorization.
+	headers []string
+	// maxBodySize truncates logged bodies. Zero logs bodies in full.
+	maxBodySize int
+}
+
+func (t debugTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := cassette.ReadBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	debugf(r.Context(), "NetBox request: %s %s\n%s\n%s",
+		r.Method, r.URL.RequestURI(), t.formatHeaders(r.Header), t.formatBody(r.URL.Path, body))
+
+	start := time.Now()
+	res, err := t.next.RoundTrip(r)
+	if err != nil {
+		debugf(r.Context(), "NetBox request failed: %s %s (%s): %v",
+			r.Method, r.URL.RequestURI(), time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	body, err = cassette.ReadBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	debugf(r.Context(), "NetBox response: %s %s: %s (%s)\n%s\n%s",
+		r.Method, r.URL.RequestURI(), res.Status, time.Since(start).Round(time.Millisecond),
+		t.formatHeaders(res.Header), t.formatBody(r.URL.Path, body))
+	return res, nil
+}
+
+func (t debugTransport) formatHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		value := strings.Join(h.Values(name), ", ")
+		if t.redactHeader(name) {
+			value = redacted
+		}
+		fmt.Fprintf(&b, "%s: %s\n", name, value)
+	}
+	return b.String()
+}
+
+func (t debugTransport) redactHeader(name string) bool {
+	for _, h := range append(cassette.RedactedHeaders, t.headers...) {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t debugTransport) formatBody(path string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	body = cassette.RedactBody(path, body)
+	if t.maxBodySize > 0 && len(body) > t.maxBodySize {
+		return fmt.Sprintf("%s... (%d more bytes)", body[:t.maxBodySize], len(body)-t.maxBodySize)
+	}
+	return string(body)
+}
+
+// debugf logs to the engine logger bound to ctx, or to the standard logger,
+// which the bridge forwards to the engine, when there is none.
+func debugf(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if ctx.Value(logging.CtxKey) != nil {
+		tfbridge.GetLogger(ctx).Debug(msg)
+		return
+	}
+	log.Printf("[DEBUG] %s", msg)
+}
+
+// warnf is debugf for warnings.
+func warnf(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if ctx.Value(logging.CtxKey) != nil {
+		tfbridge.GetLogger(ctx).Warn(msg)
+		return
+	}
+	log.Printf("[WARN] %s", msg)
+}
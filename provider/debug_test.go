@@ -0,0 +1,82 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebugTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		if strings.HasPrefix(r.URL.Path, "/api/users/tokens/") {
+			io.WriteString(w, `{"id":1,"key":"0123456789abcdef","description":"`+strings.Repeat("x", 100)+`"}`)
+			return
+		}
+		io.WriteString(w, `{"id":1,"username":"alice"}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	client := &http.Client{Transport: headerTransport{
+		headers: map[string]interface{}{"X-Proxy-Secret": "hunter2"},
+		next:    debugTransport{next: http.DefaultTransport, headers: []string{"X-Proxy-Secret"}, maxBodySize: 64},
+	}}
+	for path, body := range map[string]string{
+		"/api/users/tokens/": `{"user":1}`,
+		"/api/users/users/":  `{"username":"alice","password":"correct horse"}`,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Token s3cr3t")
+		req.Header.Set("X-CSRFToken", "csrf-s3cr3t")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if path == "/api/users/tokens/" && !strings.Contains(string(b), "0123456789abcdef") {
+			t.Errorf("debug logging altered the response: %s", b)
+		}
+	}
+
+	logged := out.String()
+	for _, secret := range []string{"s3cr3t", "csrf-s3cr3t", "hunter2", "0123456789abcdef", "correct horse"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("log contains %q:\n%s", secret, logged)
+		}
+	}
+	for _, want := range []string{
+		"NetBox request: POST /api/users/users/",
+		"NetBox response: POST /api/users/tokens/: 201 Created",
+		"X-Proxy-Secret: REDACTED",
+		`"username":"alice"`,
+		"more bytes)",
+	} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("log does not contain %q:\n%s", want, logged)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// The ways a resource with a status can be deleted.
+const (
+	// deleteObject deletes the object from NetBox.
+	deleteObject = "delete"
+	// retainObject leaves the object in NetBox untouched.
+	retainObject = "retain"
+	// statusObject leaves the object in NetBox with a retired status.
+	statusObject = "status"
+)
+
+var deleteBehaviors = []string{deleteObject, retainObject, statusObject}
+
+// retiredStatuses is the status set by the status delete behaviour, unless
+// configured otherwise, for each resource with a status.
+var retiredStatuses = map[string]string{
+	"netbox_available_ip_address": "deprecated",
+	"netbox_available_prefix":     "deprecated",
+	"netbox_available_vlan":       "deprecated",
+	"netbox_cable":                "decommissioning",
+	"netbox_circuit":              "decommissioned",
+	"netbox_device":               "decommissioning",
+	"netbox_ip_address":           "deprecated",
+	"netbox_ip_range":             "deprecated",
+	"netbox_module":               "decommissioning",
+	"netbox_power_feed":           "offline",
+	"netbox_prefix":               "deprecated",
+	"netbox_rack":                 "deprecated",
+	"netbox_site":                 "decommissioning",
+	"netbox_virtual_machine":      "decommissioning",
+	"netbox_vlan":                 "deprecated",
+	"netbox_vpn_tunnel":           "disabled",
+}
+
+// deleteConfig is the provider-wide delete behaviour.
+type deleteConfig struct {
+	Behavior string
+	// Status overrides the retired status of every resource.
+	Status string
+	// Tag, when set, is added to objects retired with a status.
+	Tag string
+}
+
+// deleteBehaviorResource lets resources with a status retain their object, or
+// retire it with a status, instead of deleting it.
+func deleteBehaviorResource(name string, r *schema.Resource) {
+	typ, ok := objectTypes[name]
+	if _, hasStatus := r.Schema["status"]; !ok || !hasStatus {
+		return
+	}
+
+	r.Schema["delete_behavior"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringInSlice(deleteBehaviors, false),
+		Description:  "What deleting this resource does to its NetBox object: `delete` it, `retain` it untouched, or set its `status` to the delete status. Defaults to the provider `delete_behavior`.",
+	}
+	r.Schema["delete_status"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "The status set when the resource is deleted with the `status` behaviour. Defaults to the provider `delete_status`, or to `" + retiredStatuses[name] + "`.",
+	}
+
+	del := r.DeleteContext
+	r.DeleteContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		cfg := configFrom(meta).Delete
+		switch deleteBehavior(r, d, meta) {
+		case retainObject:
+			d.SetId("")
+			return nil
+		case statusObject:
+			status, _ := d.Get("delete_status").(string)
+			if status == "" && cfg != nil {
+				status = cfg.Status
+			}
+			if status == "" {
+				status = retiredStatuses[name]
+			}
+			var tag string
+			if cfg != nil {
+				tag = cfg.Tag
+			}
+			for _, id := range allocatedIDs(name, d) {
+				if err := retireObject(ctx, meta, typ.endpoint, id, status, tag); err != nil {
+					return diag.FromErr(err)
+				}
+			}
+			d.SetId("")
+			return nil
+		}
+		return del(ctx, d, meta)
+	}
+}
+
+// deleteBehavior returns what deleting d, a resource of r, does to its
+// object: the resource delete_behavior, else the provider one, else
+// deleteObject. Resources without a status are always deleted.
+func deleteBehavior(r *schema.Resource, d *schema.ResourceData, meta interface{}) string {
+	if _, ok := r.Schema["delete_behavior"]; !ok {
+		return deleteObject
+	}
+	behavior, _ := d.Get("delete_behavior").(string)
+	if cfg := configFrom(meta).Delete; behavior == "" && cfg != nil {
+		behavior = cfg.Behavior
+	}
+	if behavior == "" {
+		return deleteObject
+	}
+	return behavior
+}
+
+// retireObject sets the status of an object and adds tag to it.
+func retireObject(ctx context.Context, meta interface{}, endpoint, id, status, tag string) error {
+	path := fmt.Sprintf("%s/%s/", endpoint, id)
+	patch := map[string]interface{}{"status": status}
+	if tag != "" {
+		err := configFrom(meta).tags.ensure(tag, "", func(method string, query url.Values, body interface{}) (map[string]interface{}, error) {
+			res, err := apiCall(ctx, meta, method, "extras/tags/", query, body)
+			obj, _ := res.(map[string]interface{})
+			return obj, err
+		})
+		if err != nil {
+			return err
+		}
+		obj, err := apiCall(ctx, meta, http.MethodGet, path, nil, nil)
+		if err != nil {
+			return err
+		}
+		tags := []interface{}{}
+		existing, _ := obj.(map[string]interface{})["tags"].([]interface{})
+		for _, t := range existing {
+			t, _ := t.(map[string]interface{})
+			if t["name"] == tag {
+				continue
+			}
+			tags = append(tags, map[string]interface{}{"name": t["name"], "slug": t["slug"]})
+		}
+		patch["tags"] = append(tags, map[string]interface{}{"name": tag, "slug": tagSlug(tag)})
+	}
+	_, err := apiCall(ctx, meta, http.MethodPatch, path, nil, patch)
+	return err
+}
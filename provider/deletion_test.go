@@ -0,0 +1,108 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestDeleteBehavior(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := newTestProvider(t, netbox, resource.PropertyMap{
+		"deleteBehavior": resource.NewStringProperty("status"),
+		"deleteTag":      resource.NewStringProperty("retired"),
+	})
+
+	tests := []struct {
+		name     string
+		tfName   string
+		inputs   map[string]interface{}
+		endpoint string
+		// status is the status left on the object, or empty if it is deleted.
+		status string
+		tagged bool
+	}{
+		{
+			name:     "provider status",
+			tfName:   "netbox_prefix",
+			inputs:   map[string]interface{}{"prefix": "10.0.0.0/24", "status": "active"},
+			endpoint: "ipam/prefixes",
+			status:   "deprecated",
+			tagged:   true,
+		},
+		{
+			name:     "resource status",
+			tfName:   "netbox_site",
+			inputs:   map[string]interface{}{"name": "par1", "deleteStatus": "retired"},
+			endpoint: "dcim/sites",
+			status:   "retired",
+			tagged:   true,
+		},
+		{
+			name:     "resource retain",
+			tfName:   "netbox_site",
+			inputs:   map[string]interface{}{"name": "par2", "deleteBehavior": "retain"},
+			endpoint: "dcim/sites",
+			status:   "active",
+		},
+		{
+			name:     "resource delete",
+			tfName:   "netbox_ip_address",
+			inputs:   map[string]interface{}{"ipAddress": "10.0.0.1/24", "status": "active", "deleteBehavior": "delete"},
+			endpoint: "ipam/ip-addresses",
+		},
+		{
+			name:     "no status field",
+			tfName:   "netbox_tenant",
+			inputs:   map[string]interface{}{"name": "customer"},
+			endpoint: "tenancy/tenants",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urn := p.urn(tt.tfName, "test")
+			id, state := p.create(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(tt.inputs)))
+			p.delete(urn, id, state)
+
+			objectID, _ := strconv.ParseInt(id, 10, 64)
+			obj, ok := netbox.Get(tt.endpoint, objectID)
+			if tt.status == "" {
+				if ok {
+					t.Fatalf("object was not deleted: %v", obj)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("object was deleted")
+			}
+			if status, _ := obj["status"].(map[string]interface{}); status["value"] != tt.status {
+				t.Errorf("status %v, want %s", obj["status"], tt.status)
+			}
+			if hasTag(obj, "retired") != tt.tagged {
+				t.Errorf("tags %v, want retired tag: %v", obj["tags"], tt.tagged)
+			}
+		})
+	}
+
+	if tags := netbox.List("extras/tags"); len(tags) != 1 || tags[0]["slug"] != tagSlug("retired") {
+		t.Errorf("got tags %v, want the retired tag only", tags)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceGenericObject manages an object of any NetBox REST endpoint, including
+// those of plugins, from a JSON body.
+func resourceGenericObject() *schema.Resource {
+	return &schema.Resource{
+		Description: `:meta:subcategory:Extras:This resource manages an object of any NetBox REST endpoint, including the endpoints of plugins, for the models without a dedicated resource. Only the keys set in ` + "`body`" + ` are compared with NetBox.`,
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringDoesNotContainAny("?#"),
+				StateFunc:    func(v interface{}) string { return endpointPath(v.(string)) },
+				Description:  "The API path of the endpoint, relative to `/api/`, such as `plugins/netbox-dns/zones`.",
+			},
+			"body": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: func(_, old, new string, _ *schema.ResourceData) bool { return jsonEqual(old, new) },
+				Description:      "The JSON object written to the endpoint. Removing a key leaves the field as it is in NetBox.",
+			},
+			"object": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The JSON object returned by NetBox, with every field.",
+			},
+		},
+		CreateContext: createGeneric,
+		ReadContext:   readGeneric,
+		UpdateContext: updateGeneric,
+		DeleteContext: deleteGeneric,
+		Importer: &schema.ResourceImporter{
+			StateContext: importGeneric,
+		},
+	}
+}
+
+// endpointPath returns path without the API root and surrounding slashes.
+func endpointPath(path string) string {
+	path = strings.Trim(path, "/")
+	path = strings.TrimPrefix(path, "api/")
+	return strings.Trim(path, "/")
+}
+
+func objectPath(d *schema.ResourceData) string {
+	return endpointPath(d.Get("path").(string)) + "/" + d.Id() + "/"
+}
+
+func createGeneric(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(d.Get("body").(string)), &body); err != nil {
+		return diag.FromErr(err)
+	}
+	res, err := apiCall(ctx, meta, http.MethodPost, endpointPath(d.Get("path").(string))+"/", nil, body)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	obj, _ := res.(map[string]interface{})
+	if refID(obj) == 0 {
+		return diag.Errorf("%s returned no object ID", d.Get("path"))
+	}
+	d.SetId(strconv.FormatInt(refID(obj), 10))
+	return diag.FromErr(setGeneric(d, obj))
+}
+
+func readGeneric(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	res, err := apiCall(ctx, meta, http.MethodGet, objectPath(d), nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	obj, _ := res.(map[string]interface{})
+	return diag.FromErr(setGeneric(d, obj))
+}
+
+func updateGeneric(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(d.Get("body").(string)), &body); err != nil {
+		return diag.FromErr(err)
+	}
+	res, err := apiCall(ctx, meta, http.MethodPatch, objectPath(d), nil, body)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	obj, _ := res.(map[string]interface{})
+	return diag.FromErr(setGeneric(d, obj))
+}
+
+func deleteGeneric(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	_, err := apiCall(ctx, meta, http.MethodDelete, objectPath(d), nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil
+	}
+	return diag.FromErr(err)
+}
+
+// importGeneric imports an object from an ID of the form <path>/<id>, such as
+// plugins/netbox-dns/zones/12. Its body is empty until the next update.
+func importGeneric(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	path := endpointPath(d.Id())
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid ID %q, want <path>/<id>", d.Id())
+	}
+	if _, err := strconv.ParseInt(path[i+1:], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid ID %q, want <path>/<id>", d.Id())
+	}
+	d.SetId(path[i+1:])
+	if err := d.Set("path", path[:i]); err != nil {
+		return nil, err
+	}
+	if err := d.Set("body", "{}"); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// setGeneric sets the object of d to obj, and its body to the fields of
+// obj it sets, in the shape it sets them.
+func setGeneric(d *schema.ResourceData, obj map[string]interface{}) error {
+	var body interface{}
+	if err := json.Unmarshal([]byte(d.Get("body").(string)), &body); err != nil {
+		return err
+	}
+	projected, err := json.Marshal(project(body, obj))
+	if err != nil {
+		return err
+	}
+	full, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := d.Set("body", string(projected)); err != nil {
+		return err
+	}
+	return d.Set("object", string(full))
+}
+
+// project returns the part of have that want sets, in the shape of want:
+// the keys of want for objects, the order of want for lists of the same
+// items, and the ID or value of nested objects and choices set by their ID or
+// value.
+func project(want, have interface{}) interface{} {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		nested, ok := have.(map[string]interface{})
+		if !ok {
+			return have
+		}
+		res := make(map[string]interface{}, len(want))
+		for k, v := range want {
+			res[k] = project(v, nested[k])
+		}
+		return res
+	case []interface{}:
+		items, ok := have.([]interface{})
+		if !ok {
+			return have
+		}
+		res := make([]interface{}, len(items))
+		for i, item := range items {
+			switch {
+			case i < len(want):
+				res[i] = project(want[i], item)
+			case len(want) > 0:
+				res[i] = project(want[0], item)
+			default:
+				res[i] = item
+			}
+		}
+		// NetBox returns many-to-many fields, such as tags, in its own order,
+		// so the same items as want are kept in the order of want.
+		if sameItems(want, res) {
+			return want
+		}
+		return res
+	}
+	if nested, ok := have.(map[string]interface{}); ok {
+		if _, isNumber := want.(float64); isNumber {
+			if id, ok := nested["id"]; ok {
+				return id
+			}
+		}
+		if value, ok := nested["value"]; ok {
+			return value
+		}
+	}
+	return have
+}
+
+// sameItems reports whether a and b hold the same JSON values, in any order.
+func sameItems(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, v := range a {
+		k, _ := json.Marshal(v)
+		counts[string(k)]++
+	}
+	for _, v := range b {
+		k, _ := json.Marshal(v)
+		if counts[string(k)] == 0 {
+			return false
+		}
+		counts[string(k)]--
+	}
+	return true
+}
+
+// jsonEqual reports whether a and b hold the same JSON value.
+func jsonEqual(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// dataSourceGenericObjects lists the objects of any NetBox REST endpoint.
+func dataSourceGenericObjects() *schema.Resource {
+	return &schema.Resource{
+		Description: `:meta:subcategory:Extras:Lists the objects of any NetBox REST endpoint, including the endpoints of plugins, matching NetBox query filters. Every page of results is requested.`,
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringDoesNotContainAny("?#"),
+				Description:  "The API path of the endpoint, relative to `/api/`, such as `dcim/cables`.",
+			},
+			"filters": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateFilters,
+				Description:  "The query filters, by name, such as `site` or `name__ic`. A filter set to a JSON array of strings is repeated for each of them, and matches any. `limit`, `offset` and `fields` are set by the function and cannot be filters.",
+			},
+			"fields": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The fields of the objects to return. Defaults to every field.",
+			},
+			"limit": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "The maximum number of objects to return. Defaults to all of them.",
+			},
+			"objects": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeMap, Elem: &schema.Schema{Type: schema.TypeString}},
+				Description: "The objects, in the order NetBox returns them, as maps of their fields to their values. Strings, numbers and booleans are given as text, null as an empty string, and nested objects and lists as JSON.",
+			},
+			"objects_json": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The objects, in the order NetBox returns them, as a JSON array.",
+			},
+		},
+		ReadContext: readGenericObjects,
+	}
+}
+
+func readGenericObjects(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	path := endpointPath(d.Get("path").(string)) + "/"
+	query := url.Values{}
+	for name, value := range d.Get("filters").(map[string]interface{}) {
+		var values []string
+		if err := json.Unmarshal([]byte(value.(string)), &values); err != nil {
+			values = []string{value.(string)}
+		}
+		query[name] = values
+	}
+	var fields []string
+	for _, field := range d.Get("fields").([]interface{}) {
+		fields = append(fields, field.(string))
+	}
+	if len(fields) > 0 {
+		// NetBox 4 only renders the fields asked for. Older versions ignore
+		// the parameter, so the fields are also picked below.
+		query.Set("fields", strings.Join(fields, ","))
+	}
+
+	objects, err := listObjects(ctx, meta, path, query, d.Get("limit").(int))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	results := make([]interface{}, 0, len(objects))
+	flat := make([]map[string]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		if len(fields) > 0 {
+			picked := make(map[string]interface{}, len(fields))
+			for _, field := range fields {
+				if v, ok := obj[field]; ok {
+					picked[field] = v
+				}
+			}
+			obj = picked
+		}
+		fieldValues, err := fieldTexts(obj)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		results = append(results, obj)
+		flat = append(flat, fieldValues)
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(path + "?" + query.Encode())
+	if err := d.Set("objects", flat); err != nil {
+		return diag.FromErr(err)
+	}
+	return diag.FromErr(d.Set("objects_json", string(b)))
+}
+
+// reservedFilters are the query parameters getObjects sets itself.
+var reservedFilters = []string{"fields", "limit", "offset"}
+
+func validateFilters(v interface{}, key string) ([]string, []error) {
+	var errs []error
+	for _, name := range reservedFilters {
+		if _, ok := v.(map[string]interface{})[name]; ok {
+			errs = append(errs, fmt.Errorf("%s cannot be set in %s: it is set by the function", name, key))
+		}
+	}
+	return nil, errs
+}
+
+// fieldTexts returns the fields of a JSON object as text, as fieldText does.
+func fieldTexts(obj map[string]interface{}) (map[string]interface{}, error) {
+	texts := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		text, err := fieldText(v)
+		if err != nil {
+			return nil, err
+		}
+		texts[k] = text
+	}
+	return texts, nil
+}
+
+// fieldText returns the value of a field of a JSON object as text: strings as
+// they are, numbers and booleans as written in JSON, null as an empty string,
+// and objects and lists as JSON.
+func fieldText(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,194 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e-breuninger/terraform-provider-netbox/netbox"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestGenericObject(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	group := netbox.Create("ipam/vlan-groups", map[string]interface{}{"name": "dc1", "slug": "dc1"})
+	p := newTestProvider(t, netbox, nil)
+
+	// The group is set by ID and the status by value, while NetBox returns
+	// a nested object and a choice.
+	body := fmt.Sprintf(`{"group": %d, "name": "users", "status": "active", "vid": 10}`, group)
+	urn := p.urn("netbox_object", "users")
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{"path": "/api/ipam/vlans/", "body": body}))
+	id, state := p.create(urn, inputs)
+	if !jsonEqual(state["body"].StringValue(), body) {
+		t.Errorf("body = %s, want %s", state["body"].StringValue(), body)
+	}
+	if got := state["path"].StringValue(); got != "ipam/vlans" {
+		t.Errorf("path = %q, want ipam/vlans", got)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(state["object"].StringValue()), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj["display"] != "users" || obj["status"].(map[string]interface{})["label"] != "Active" {
+		t.Errorf("object = %v, want the VLAN as NetBox returns it", obj)
+	}
+
+	// Fields outside of the body are not compared, those in it are.
+	patch := func(fields string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPatch, netbox.URL+"/api/ipam/vlans/"+id+"/", strings.NewReader(fields))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("patch %s: %v %v", fields, res, err)
+		}
+		res.Body.Close()
+	}
+	patch(`{"description": "changed"}`)
+	_, state, _ = p.read(urn, id, state, inputs)
+	if !jsonEqual(state["body"].StringValue(), body) {
+		t.Errorf("body after a change of another field = %s, want %s", state["body"].StringValue(), body)
+	}
+	patch(`{"name": "guests"}`)
+	_, state, _ = p.read(urn, id, state, inputs)
+	var refreshed map[string]interface{}
+	json.Unmarshal([]byte(state["body"].StringValue()), &refreshed)
+	if refreshed["name"] != "guests" {
+		t.Errorf("body after a change of its name = %s, want the new name", state["body"].StringValue())
+	}
+
+	// NetBox returns tags in its own order, which is not a change.
+	a := netbox.Create("extras/tags", map[string]interface{}{"name": "a", "slug": "a"})
+	b := netbox.Create("extras/tags", map[string]interface{}{"name": "b", "slug": "b"})
+	tagged := inputs.Copy()
+	tagged["body"] = resource.NewStringProperty(fmt.Sprintf(`{"name": "guests", "tags": [%d, %d]}`, b, a))
+	tagged = p.check(urn, inputs, tagged)
+	state = p.update(urn, id, state, tagged)
+	patch(fmt.Sprintf(`{"tags": [%d, %d]}`, a, b))
+	_, state, _ = p.read(urn, id, state, tagged)
+	if got, want := state["body"].StringValue(), tagged["body"].StringValue(); !jsonEqual(got, want) {
+		t.Errorf("body after NetBox reordered the tags = %s, want %s", got, want)
+	}
+
+	importID, imported, _ := p.read(urn, "ipam/vlans/"+id, nil, nil)
+	if importID != id || imported["path"].StringValue() != "ipam/vlans" {
+		t.Errorf("import returned ID %q and path %v, want %q and ipam/vlans", importID, imported["path"], id)
+	}
+
+	p.delete(urn, id, state)
+	p.assertStored("ipam/vlans", id, false)
+}
+
+func TestGenericObjects(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	// More sites than a page holds.
+	for i := 0; i < pageSize+5; i++ {
+		status := "active"
+		if i%2 == 1 {
+			status = "planned"
+		}
+		netbox.Create("dcim/sites", map[string]interface{}{"name": fmt.Sprintf("site%04d", i), "slug": fmt.Sprintf("site%04d", i), "status": status})
+	}
+	netbox.Create("dcim/sites", map[string]interface{}{"name": "lab", "slug": "lab", "status": "retired"})
+	p := newTestProvider(t, netbox, nil)
+
+	names := func(out resource.PropertyMap) []string {
+		var res []string
+		for _, v := range out["objects"].ArrayValue() {
+			res = append(res, v.ObjectValue()["name"].StringValue())
+		}
+		return res
+	}
+
+	out, err := p.invoke("netbox_objects", map[string]interface{}{
+		"path":    "dcim/sites",
+		"filters": map[string]interface{}{"status": `["active","planned"]`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(out); len(got) != pageSize+5 || got[pageSize+4] != fmt.Sprintf("site%04d", pageSize+4) {
+		t.Errorf("got %d sites, want every page of %d", len(got), pageSize+5)
+	}
+
+	out, err = p.invoke("netbox_objects", map[string]interface{}{
+		"path":    "/api/dcim/sites/",
+		"filters": map[string]interface{}{"name__isw": "SITE000", "status": "planned"},
+		"fields":  []interface{}{"id", "name"},
+		"limit":   2.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(out); !reflect.DeepEqual(got, []string{"site0001", "site0003"}) {
+		t.Errorf("filtered sites = %v, want [site0001 site0003]", got)
+	}
+	if obj := out["objects"].ArrayValue()[0].ObjectValue(); len(obj) != 2 || obj["id"].StringValue() != fmt.Sprint(netbox.List("dcim/sites")[1]["id"]) {
+		t.Errorf("object %v has other fields than id and name, or another ID", obj)
+	}
+	var objects []map[string]interface{}
+	if err := json.Unmarshal([]byte(out["objectsJson"].StringValue()), &objects); err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[1]["name"] != "site0003" || len(objects[1]) != 2 {
+		t.Errorf("objectsJson = %v, want the id and name of site0001 and site0003", objects)
+	}
+
+	// The pages are requested by the function, so filters cannot set them.
+	for _, name := range []string{"offset", "limit", "fields"} {
+		_, err := p.invoke("netbox_objects", map[string]interface{}{
+			"path":    "dcim/sites",
+			"filters": map[string]interface{}{name: "5"},
+		})
+		if err == nil || !strings.Contains(err.Error(), name+" cannot be set in filters") {
+			t.Errorf("filter %s: got error %v", name, err)
+		}
+	}
+}
+
+// TestListObjectsPaging checks a query cannot override the page listObjects
+// requests, which would fetch the same page forever.
+func TestListObjectsPaging(t *testing.T) {
+	server := fakenetbox.NewServer()
+	defer server.Close()
+	for i := 0; i < pageSize+5; i++ {
+		server.Create("dcim/sites", map[string]interface{}{"name": fmt.Sprintf("site%04d", i), "slug": fmt.Sprintf("site%04d", i)})
+	}
+	cfg := &clientConfig{Config: netbox.Config{ServerURL: server.URL, APIToken: "0123456789abcdef0123456789abcdef01234567", RequestTimeout: 10}}
+	api, err := cfg.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sites, err := listObjects(context.Background(), api, "dcim/sites/", url.Values{"offset": {"5"}, "limit": {"1"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != pageSize+5 {
+		t.Errorf("listObjects with a query offset returned %d sites, want all %d", len(sites), pageSize+5)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// goneResource makes a failed read of r report the resource as deleted when
+// its object no longer exists. Upstream reads only recognise the 404 of their
+// main lookup, and only when NetBox itself answers it; a 404 from a follow-up
+// lookup, or one rewritten by a proxy, fails them instead, and some panic on
+// it.
+func goneResource(name string, r *schema.Resource) {
+	typ, ok := objectTypes[name]
+	if !ok {
+		typ, ok = fieldObjects[name]
+	}
+	if !ok || r.ReadContext == nil {
+		return
+	}
+
+	read := r.ReadContext
+	r.ReadContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		diags := recoverRead(ctx, read, d, meta)
+		if !diags.HasError() || d.Id() == "" {
+			return diags
+		}
+		gone, err := objectGone(ctx, meta, typ.endpoint, d.Id())
+		if err != nil || !gone {
+			return diags
+		}
+		debugf(ctx, "%s %s was deleted outside of Pulumi", typ.endpoint, d.Id())
+		d.SetId("")
+		return nil
+	}
+}
+
+// objectGone reports whether the object id of endpoint does not exist.
+func objectGone(ctx context.Context, meta interface{}, endpoint, id string) (bool, error) {
+	_, err := apiCall(ctx, meta, http.MethodGet, fmt.Sprintf("%s/%s/", endpoint, id), nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return true, nil
+	}
+	return false, err
+}
+
+// recoverRead runs read, turning a panic into an error.
+func recoverRead(ctx context.Context, read schema.ReadContextFunc, d *schema.ResourceData, meta interface{}) (diags diag.Diagnostics) {
+	defer func() {
+		if p := recover(); p != nil {
+			diags = diag.Errorf("reading %s failed: %v", d.Id(), p)
+		}
+	}()
+	return read(ctx, d, meta)
+}
@@ -0,0 +1,91 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+// TestReadGone checks that refreshing every resource whose object was deleted
+// out of band removes it from the state, whether NetBox or a proxy in front of
+// it answers the 404.
+func TestReadGone(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	var proxied atomic.Bool
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if !proxied.Load() || r.Header.Get("X-Proxied") != "" {
+			return false
+		}
+		rec := httptest.NewRecorder()
+		inner := r.Clone(r.Context())
+		inner.Header.Set("X-Proxied", "1")
+		netbox.ServeHTTP(rec, inner)
+		if rec.Code != http.StatusNotFound {
+			for k, v := range rec.Header() {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.Code)
+			w.Write(rec.Body.Bytes())
+			return true
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<html><body><h1>404 Not Found</h1></body></html>")
+		return true
+	})
+
+	for tfName := range lifecycleCases(nil) {
+		tfName := tfName
+		t.Run(tfName, func(t *testing.T) {
+			proxied.Store(false)
+			netbox.Reset()
+			tc := lifecycleCases(fixtures(netbox))[tfName]
+			p := newTestProvider(t, netbox, nil)
+			urn := p.urn(tfName, "test")
+
+			inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(tc.inputs))
+			id, state := p.create(urn, inputs)
+			endpoint := tc.endpoint
+			if endpoint == "" {
+				endpoint = fieldObjects[tfName].endpoint
+			}
+			objectID, _ := strconv.ParseInt(id, 10, 64)
+			if !netbox.Delete(endpoint, objectID) {
+				t.Fatalf("%s %d is not stored", endpoint, objectID)
+			}
+
+			readID, _, _ := p.read(urn, id, state, inputs)
+			if readID != "" {
+				t.Errorf("read returned ID %q, want the resource gone", readID)
+			}
+
+			proxied.Store(true)
+			readID, _, _ = p.read(urn, id, state, inputs)
+			if readID != "" {
+				t.Errorf("read behind a proxy returned ID %q, want the resource gone", readID)
+			}
+		})
+	}
+}
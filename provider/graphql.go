@@ -0,0 +1,205 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// graphqlError is an error of a GraphQL response.
+type graphqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+func (e graphqlError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s (at %s)", e.Message, strings.Join(path, "."))
+}
+
+// graphqlCall sends query and its variables to the GraphQL API of NetBox,
+// authenticated and through the same transport as apiCall, and returns the
+// data of the response. GraphQL errors fail the call.
+func graphqlCall(ctx context.Context, meta interface{}, query string, variables map[string]interface{}) (interface{}, error) {
+	cfg := configFrom(meta)
+	if cfg.httpClient == nil {
+		return nil, fmt.Errorf("unexpected provider meta %T", meta)
+	}
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.graphqlURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+cfg.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := cfg.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		Data   interface{}    `json:"data"`
+		Errors []graphqlError `json:"errors"`
+	}
+	if json.Unmarshal(b, &out) != nil || res.StatusCode >= 300 && len(out.Errors) == 0 {
+		return nil, &apiError{Method: http.MethodPost, Path: req.URL.Path, Status: res.StatusCode, Body: string(b)}
+	}
+	if len(out.Errors) > 0 {
+		messages := make([]string, len(out.Errors))
+		for i, e := range out.Errors {
+			messages[i] = e.String()
+		}
+		return nil, fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+	return out.Data, nil
+}
+
+func dataSourceGraphQLQuery() *schema.Resource {
+	return &schema.Resource{
+		Description: `:meta:subcategory:Extras:Runs a query against the GraphQL API of NetBox, with the authentication and headers of the provider. Only the top-level fields of the response are structured, in ` + "`results`" + `: their nested selections are JSON strings, so deeper levels are read from ` + "`data`" + `.`,
+		Schema: map[string]*schema.Schema{
+			"query": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The GraphQL query, which may declare variables.",
+			},
+			"variables": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsJSON,
+				Description:  "The variables of the query, as a JSON object.",
+			},
+			"data": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The data of the response, as JSON.",
+			},
+			"results": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"field": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name, or alias, of the top-level field of the query.",
+						},
+						"objects": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeMap, Elem: &schema.Schema{Type: schema.TypeString}},
+							Description: "The objects of the field, as maps of their fields to their values, as `getObjects` returns them: strings, numbers and booleans as text, null as an empty string, and nested objects and lists as JSON. A field holding a single object gives one object, a null field none, and a scalar an object with its `value`.",
+						},
+					},
+				},
+				Description: "The top-level fields of the data of the response, sorted by name, with their objects. Only this level is structured, nested selections are JSON.",
+			},
+		},
+		ReadContext: readGraphQLQuery,
+	}
+}
+
+func readGraphQLQuery(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var variables map[string]interface{}
+	if v := d.Get("variables").(string); v != "" {
+		if err := json.Unmarshal([]byte(v), &variables); err != nil {
+			return diag.Errorf("variables are not a JSON object: %v", err)
+		}
+	}
+	data, err := graphqlCall(ctx, meta, d.Get("query").(string), variables)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	results, err := graphqlResults(data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	sum := sha256.Sum256([]byte(d.Get("query").(string) + "\x00" + d.Get("variables").(string)))
+	d.SetId(hex.EncodeToString(sum[:8]))
+	if err := d.Set("results", results); err != nil {
+		return diag.FromErr(err)
+	}
+	return diag.FromErr(d.Set("data", string(b)))
+}
+
+// graphqlResults returns the top-level fields of data, sorted by name, with
+// their objects flattened to text.
+func graphqlResults(data interface{}) ([]interface{}, error) {
+	fields, _ := data.(map[string]interface{})
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		var items []interface{}
+		switch v := fields[name].(type) {
+		case nil:
+		case []interface{}:
+			items = v
+		default:
+			items = []interface{}{v}
+		}
+		objects := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				obj = map[string]interface{}{"value": item}
+			}
+			texts, err := fieldTexts(obj)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, texts)
+		}
+		results = append(results, map[string]interface{}{"field": name, "objects": objects})
+	}
+	return results, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestGraphQLQuery(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/graphql/" {
+			return false
+		}
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Authorization"), "Token ") || r.Header.Get("X-Proxy-Secret") != "hunter2" {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(req.Query, "bogus") {
+			w.Write([]byte(`{"data": null, "errors": [{"message": "Cannot query field 'bogus' on type 'DeviceType'.", "path": ["device", 0]}]}`))
+			return true
+		}
+		if strings.Contains(req.Query, "interface_list") {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"interface_list": []interface{}{
+						map[string]interface{}{"name": "eth0", "mtu": 9000, "device": map[string]interface{}{"name": "leaf1"}},
+						map[string]interface{}{"name": "eth1", "mtu": nil, "device": map[string]interface{}{"name": "leaf1"}},
+					},
+					"site": nil,
+				},
+			})
+			return true
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"device": map[string]interface{}{"id": req.Variables["id"], "name": "leaf1"}},
+		})
+		return true
+	})
+	p := newTestProvider(t, netbox, resource.PropertyMap{
+		"readOnly": resource.NewBoolProperty(true),
+		"headers":  resource.NewObjectProperty(resource.PropertyMap{"X-Proxy-Secret": resource.NewStringProperty("hunter2")}),
+	})
+
+	out, err := p.invoke("netbox_graphql_query", map[string]interface{}{
+		"query":     "query ($id: ID!) { device(id: $id) { id name } }",
+		"variables": `{"id": "7"}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out["data"].StringValue(), `{"device":{"id":"7","name":"leaf1"}}`; got != want {
+		t.Errorf("data = %s, want %s", got, want)
+	}
+	results := out["results"].ArrayValue()
+	if len(results) != 1 || results[0].ObjectValue()["field"].StringValue() != "device" {
+		t.Fatalf("results = %v, want the device field", results)
+	}
+	if objects := results[0].ObjectValue()["objects"].ArrayValue(); len(objects) != 1 || objects[0].ObjectValue()["name"].StringValue() != "leaf1" {
+		t.Errorf("device objects = %v, want leaf1", objects)
+	}
+
+	out, err = p.invoke("netbox_graphql_query", map[string]interface{}{
+		"query": "{ interface_list { name mtu device { name } } site(id: 99) { name } }",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results = out["results"].ArrayValue()
+	if len(results) != 2 {
+		t.Fatalf("results = %v, want interface_list and site", results)
+	}
+	if field := results[1].ObjectValue(); field["field"].StringValue() != "site" || len(field["objects"].ArrayValue()) != 0 {
+		t.Errorf("null site = %v, want no objects", field)
+	}
+	interfaces := results[0].ObjectValue()["objects"].ArrayValue()
+	if len(interfaces) != 2 {
+		t.Fatalf("interfaces = %v, want 2", interfaces)
+	}
+	for k, want := range map[string]string{"name": "eth0", "mtu": "9000", "device": `{"name":"leaf1"}`} {
+		if got := interfaces[0].ObjectValue()[resource.PropertyKey(k)].StringValue(); got != want {
+			t.Errorf("interface %s = %q, want %q", k, got, want)
+		}
+	}
+	if got := interfaces[1].ObjectValue()["mtu"].StringValue(); got != "" {
+		t.Errorf("null mtu = %q, want an empty string", got)
+	}
+
+	_, err = p.invoke("netbox_graphql_query", map[string]interface{}{"query": "{ device(id: 7) { bogus } }"})
+	if err == nil || !strings.Contains(err.Error(), "Cannot query field 'bogus' on type 'DeviceType'. (at device.0)") {
+		t.Errorf("query with an error: got error %v", err)
+	}
+}
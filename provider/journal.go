@@ -0,0 +1,189 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	netboxclient "github.com/fbreckle/go-netbox/netbox/client"
+	"github.com/fbreckle/go-netbox/netbox/client/extras"
+	"github.com/fbreckle/go-netbox/netbox/models"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// journalKinds are the severities NetBox accepts for journal entries.
+var journalKinds = []string{"info", "success", "warning", "danger"}
+
+// unjournaled lists the content types whose objects have no journal.
+var unjournaled = map[string]bool{
+	"extras.customfield":          true,
+	"extras.customfieldchoiceset": true,
+	"extras.eventrule":            true,
+	"extras.tag":                  true,
+	"extras.webhook":              true,
+	"tenancy.contactassignment":   true,
+	"users.objectpermission":      true,
+	"users.token":                 true,
+	"users.user":                  true,
+}
+
+// journalConfig selects the changes recorded in the NetBox journal.
+type journalConfig struct {
+	// Types lists the resource types to journal, as type tokens such as
+	// netbox:index/device:Device or as their names, such as Device. Empty
+	// journals every type.
+	Types []string
+	// Kind is the severity of the entries.
+	Kind string
+}
+
+func journalChangesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Add a journal entry to every object the provider creates or updates, naming the stack, the project and the changed properties.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"types": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+					Description: "The resource types to journal, as type tokens such as `netbox:index/device:Device` or names such as `Device`. Defaults to every type that supports journal entries.",
+				},
+				"kind": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "info",
+					ValidateFunc: validation.StringInSlice(journalKinds, false),
+					Description:  "The kind of the journal entries: one of `info`, `success`, `warning` or `danger`. Defaults to `info`.",
+				},
+			},
+		},
+	}
+}
+
+// readJournalConfig returns the journal_changes configuration, or nil when
+// journaling is off.
+func readJournalConfig(data *schema.ResourceData) *journalConfig {
+	blocks, _ := data.Get("journal_changes").([]interface{})
+	if len(blocks) == 0 {
+		return nil
+	}
+	cfg := &journalConfig{Kind: "info"}
+	// An empty block enables journaling with the defaults.
+	block, _ := blocks[0].(map[string]interface{})
+	if kind, _ := block["kind"].(string); kind != "" {
+		cfg.Kind = kind
+	}
+	types, _ := block["types"].([]interface{})
+	for _, t := range types {
+		if t, ok := t.(string); ok && t != "" {
+			cfg.Types = append(cfg.Types, t)
+		}
+	}
+	return cfg
+}
+
+// journals reports whether changes to resources of type tok are journaled.
+func (cfg *journalConfig) journals(tok string) bool {
+	if len(cfg.Types) == 0 {
+		return true
+	}
+	name := tok
+	if i := strings.LastIndex(tok, ":"); i >= 0 {
+		name = tok[i+1:]
+	}
+	for _, t := range cfg.Types {
+		if t == tok || t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// journalChange adds a journal entry describing a successful create or update
+// to the object of resource r. A failure to do so is reported as a warning,
+// since the change itself went through.
+func journalChange(ctx context.Context, name string, r *schema.Resource, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	cfg := configFrom(meta).Journal
+	op, ok := operationFrom(ctx)
+	contentType := objectTypes[name].contentType
+	if cfg == nil || !ok || op.URN == "" || !cfg.journals(string(op.URN.Type())) {
+		return nil
+	}
+	id, err := strconv.ParseInt(d.Id(), 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	var changed []string
+	for k, s := range r.Schema {
+		if (s.Computed && !s.Optional) || !d.HasChange(k) {
+			continue
+		}
+		changed = append(changed, "`"+pulumiName(r, k)+"`")
+	}
+	sort.Strings(changed)
+	if len(changed) == 0 {
+		changed = []string{"none"}
+	}
+
+	comments := fmt.Sprintf("Pulumi %s of `%s` (`%s`) by stack `%s` of project `%s`.\n\nChanged properties: %s.\n\nURN: `%s`\nRequest ID: `%s`",
+		strings.ToLower(op.Kind), op.URN.Name(), op.URN.Type(), op.URN.Stack(), op.URN.Project(),
+		strings.Join(changed, ", "), op.URN, op.RequestID)
+	params := extras.NewExtrasJournalEntriesCreateParams().WithContext(ctx).WithData(&models.WritableJournalEntry{
+		AssignedObjectType: &contentType,
+		AssignedObjectID:   &id,
+		Comments:           &comments,
+		Kind:               cfg.Kind,
+		Tags:               []*models.NestedTag{},
+	})
+	if _, err := meta.(*netboxclient.NetBoxAPI).Extras.ExtrasJournalEntriesCreate(params, nil); err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Could not add a journal entry",
+			Detail:   fmt.Sprintf("The %s succeeded, but the journal entry for %s %d could not be added: %v", strings.ToLower(op.Kind), contentType, id, err),
+		}}
+	}
+	return nil
+}
+
+// journalResource journals the successful creates and updates of r.
+func journalResource(name string, r *schema.Resource) {
+	if typ, ok := objectTypes[name]; !ok || unjournaled[typ.contentType] {
+		return
+	}
+	journaled := func(f schema.CreateContextFunc) schema.CreateContextFunc {
+		if f == nil {
+			return nil
+		}
+		return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			diags := f(ctx, d, meta)
+			if diags.HasError() || d.Id() == "" {
+				return diags
+			}
+			return append(diags, journalChange(ctx, name, r, d, meta)...)
+		}
+	}
+	r.CreateContext = journaled(r.CreateContext)
+	r.UpdateContext = schema.UpdateContextFunc(journaled(schema.CreateContextFunc(r.UpdateContext)))
+}
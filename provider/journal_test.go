@@ -0,0 +1,77 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestJournalChanges(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	ids := fixtures(netbox)
+
+	p := newTestProvider(t, netbox, resource.PropertyMap{
+		"journalChanges": resource.NewObjectProperty(resource.PropertyMap{
+			"types": resource.NewArrayProperty([]resource.PropertyValue{resource.NewStringProperty("Device")}),
+			"kind":  resource.NewStringProperty("warning"),
+		}),
+	})
+
+	siteURN := p.urn("netbox_site", "par2")
+	p.create(siteURN, p.check(siteURN, nil, resource.PropertyMap{"name": resource.NewStringProperty("par2")}))
+	if entries := netbox.List("extras/journal-entries"); len(entries) != 0 {
+		t.Fatalf("journaled a type not selected: %v", entries)
+	}
+
+	urn := p.urn("netbox_device", "leaf3")
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"name": "leaf3", "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]), "siteId": float64(ids["site"]),
+	}))
+	id, state := p.create(urn, inputs)
+
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("rebuilt")
+	p.update(urn, id, state, p.check(urn, inputs, changed))
+
+	entries := netbox.List("extras/journal-entries")
+	if len(entries) != 2 {
+		t.Fatalf("got %d journal entries, want 2: %v", len(entries), entries)
+	}
+	for i, want := range []struct{ kind, changed string }{
+		{"create", "`deviceTypeId`, `name`, `roleId`, `siteId`"},
+		{"update", "Changed properties: `description`."},
+	} {
+		e := entries[i]
+		if e["assigned_object_type"] != "dcim.device" || fmt.Sprint(e["assigned_object_id"]) != id {
+			t.Errorf("entry %d is assigned to %v %v, want dcim.device %s", i, e["assigned_object_type"], e["assigned_object_id"], id)
+		}
+		if kind, _ := e["kind"].(map[string]interface{}); kind["value"] != "warning" {
+			t.Errorf("entry %d has kind %v, want warning", i, e["kind"])
+		}
+		comments, _ := e["comments"].(string)
+		for _, s := range []string{"Pulumi " + want.kind + " of `leaf3`", "stack `test`", "project `netbox`", want.changed, string(urn)} {
+			if !strings.Contains(comments, s) {
+				t.Errorf("entry %d does not mention %q:\n%s", i, s, comments)
+			}
+		}
+	}
+}
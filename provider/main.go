@@ -0,0 +1,163 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/cmdutil"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+)
+
+// Main launches the provider plugin. It behaves like tfbridge.Main, but wraps
+// the resource provider server so operations are traced and their API calls
+// can be correlated.
+func Main(pkg string, version string, prov tfbridge.ProviderInfo, pulumiSchema []byte) {
+	ctx := context.Background()
+
+	// Flags other than these, such as -tracing, are parsed later by the
+	// provider host, so errors are discarded here.
+	flags := flag.NewFlagSet("tf-provider-flags", flag.ContinueOnError)
+	defaultOutput := flags.Output()
+	flags.SetOutput(io.Discard)
+
+	dumpInfo := flags.Bool("get-provider-info", false, "dump provider info as JSON to stdout")
+	providerVersion := flags.Bool("version", false, "get built provider version")
+
+	err := flags.Parse(os.Args[1:])
+	if err == flag.ErrHelp {
+		flags.SetOutput(defaultOutput)
+		if err := flags.Parse(os.Args[1:]); err != nil {
+			cmdutil.ExitError(err.Error())
+		}
+	}
+
+	if *dumpInfo {
+		if err := json.NewEncoder(os.Stdout).Encode(tfbridge.MarshalProviderInfo(&prov)); err != nil {
+			cmdutil.ExitError(err.Error())
+		}
+		os.Exit(0)
+	}
+
+	if *providerVersion {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+
+	prov.P.InitLogging(ctx)
+
+	shutdown, err := setupTracing(ctx)
+	if err != nil {
+		log.Printf("[WARN] OpenTelemetry tracing disabled: %v", err)
+	}
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Printf("[WARN] flushing OpenTelemetry spans: %v", err)
+		}
+	}
+	// The engine stops providers with a signal, so stop serving gracefully on
+	// one: in-flight operations complete and the pending spans are flushed
+	// once the server returns.
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err = serve(ctx, pkg, func(host *provider.HostClient) (pulumirpc.ResourceProviderServer, error) {
+		server := tfbridge.NewProvider(context.TODO(), host, pkg, version, prov.P, prov, pulumiSchema)
+		return newServer(server), nil
+	})
+	stop()
+	if err != nil {
+		cmdutil.ExitError(err.Error())
+	}
+}
+
+// serve runs the provider server like provider.Main, but also stops it
+// gracefully when ctx is done.
+func serve(ctx context.Context, name string, provMaker func(*provider.HostClient) (pulumirpc.ResourceProviderServer, error)) error {
+	var tracing string
+	flag.StringVar(&tracing, "tracing", "", "Emit tracing to a Zipkin-compatible tracing endpoint")
+	flag.Parse()
+
+	logging.InitLogging(false, 0, false)
+	cmdutil.InitTracing(name, name, tracing)
+
+	args := flag.Args()
+	var host *provider.HostClient
+	switch len(args) {
+	case 0:
+		// Attach mode: the engine connects to the port printed below.
+	case 1:
+		var err error
+		host, err = provider.NewHostClient(args[0])
+		if err != nil {
+			return fmt.Errorf("fatal: could not connect to host RPC: %w", err)
+		}
+		// Stop serving as well if the engine fails its health check.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		if err := rpcutil.Healthcheck(ctx, args[0], 5*time.Minute, cancel); err != nil {
+			return fmt.Errorf("could not start health check host RPC server: %w", err)
+		}
+	default:
+		return errors.New("fatal: could not connect to host RPC; missing argument")
+	}
+
+	stopping := make(chan bool)
+	go func() {
+		<-ctx.Done()
+		close(stopping)
+	}()
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Cancel: stopping,
+		Init: func(srv *grpc.Server) error {
+			prov, err := provMaker(host)
+			if err != nil {
+				return fmt.Errorf("failed to create resource provider: %v", err)
+			}
+			pulumirpc.RegisterResourceProviderServer(srv, prov)
+			return nil
+		},
+		Options: rpcutil.OpenTracingServerInterceptorOptions(nil),
+	})
+	if err != nil {
+		return fmt.Errorf("fatal: %w", err)
+	}
+
+	// The engine reads the port to connect to from the first line of output.
+	fmt.Printf("%d\n", handle.Port)
+
+	if err := <-handle.Done; err != nil {
+		return fmt.Errorf("fatal: %w", err)
+	}
+	return nil
+}
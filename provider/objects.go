@@ -0,0 +1,126 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+// objectType describes the NetBox object a resource manages.
+type objectType struct {
+	// endpoint is the API path of the objects, relative to /api/.
+	endpoint string
+	// contentType is the app label and model of the objects, as used by
+	// object permissions and generic relations.
+	contentType string
+}
+
+// objectTypes maps the resources that own a NetBox object to its type.
+// Resources that only set a field of another object, such as
+// netbox_primary_ip, are not listed.
+var objectTypes = map[string]objectType{
+	"netbox_aggregate":                  {"ipam/aggregates", "ipam.aggregate"},
+	"netbox_asn":                        {"ipam/asns", "ipam.asn"},
+	"netbox_available_asn":              {"ipam/asns", "ipam.asn"},
+	"netbox_available_ip_address":       {"ipam/ip-addresses", "ipam.ipaddress"},
+	"netbox_available_prefix":           {"ipam/prefixes", "ipam.prefix"},
+	"netbox_available_vlan":             {"ipam/vlans", "ipam.vlan"},
+	"netbox_cable":                      {"dcim/cables", "dcim.cable"},
+	"netbox_circuit":                    {"circuits/circuits", "circuits.circuit"},
+	"netbox_circuit_provider":           {"circuits/providers", "circuits.provider"},
+	"netbox_circuit_termination":        {"circuits/circuit-terminations", "circuits.circuittermination"},
+	"netbox_circuit_type":               {"circuits/circuit-types", "circuits.circuittype"},
+	"netbox_cluster":                    {"virtualization/clusters", "virtualization.cluster"},
+	"netbox_cluster_group":              {"virtualization/cluster-groups", "virtualization.clustergroup"},
+	"netbox_cluster_type":               {"virtualization/cluster-types", "virtualization.clustertype"},
+	"netbox_contact":                    {"tenancy/contacts", "tenancy.contact"},
+	"netbox_contact_assignment":         {"tenancy/contact-assignments", "tenancy.contactassignment"},
+	"netbox_contact_group":              {"tenancy/contact-groups", "tenancy.contactgroup"},
+	"netbox_contact_role":               {"tenancy/contact-roles", "tenancy.contactrole"},
+	"netbox_custom_field":               {"extras/custom-fields", "extras.customfield"},
+	"netbox_custom_field_choice_set":    {"extras/custom-field-choice-sets", "extras.customfieldchoiceset"},
+	"netbox_device":                     {"dcim/devices", "dcim.device"},
+	"netbox_device_console_port":        {"dcim/console-ports", "dcim.consoleport"},
+	"netbox_device_console_server_port": {"dcim/console-server-ports", "dcim.consoleserverport"},
+	"netbox_device_front_port":          {"dcim/front-ports", "dcim.frontport"},
+	"netbox_device_interface":           {"dcim/interfaces", "dcim.interface"},
+	"netbox_device_module_bay":          {"dcim/module-bays", "dcim.modulebay"},
+	"netbox_device_power_outlet":        {"dcim/power-outlets", "dcim.poweroutlet"},
+	"netbox_device_power_port":          {"dcim/power-ports", "dcim.powerport"},
+	"netbox_device_rear_port":           {"dcim/rear-ports", "dcim.rearport"},
+	"netbox_device_role":                {"dcim/device-roles", "dcim.devicerole"},
+	"netbox_device_type":                {"dcim/device-types", "dcim.devicetype"},
+	"netbox_event_rule":                 {"extras/event-rules", "extras.eventrule"},
+	"netbox_interface":                  {"virtualization/interfaces", "virtualization.vminterface"},
+	"netbox_inventory_item":             {"dcim/inventory-items", "dcim.inventoryitem"},
+	"netbox_inventory_item_role":        {"dcim/inventory-item-roles", "dcim.inventoryitemrole"},
+	"netbox_ip_address":                 {"ipam/ip-addresses", "ipam.ipaddress"},
+	"netbox_ip_range":                   {"ipam/ip-ranges", "ipam.iprange"},
+	"netbox_ipam_role":                  {"ipam/roles", "ipam.role"},
+	"netbox_location":                   {"dcim/locations", "dcim.location"},
+	"netbox_manufacturer":               {"dcim/manufacturers", "dcim.manufacturer"},
+	"netbox_module":                     {"dcim/modules", "dcim.module"},
+	"netbox_module_type":                {"dcim/module-types", "dcim.moduletype"},
+	"netbox_permission":                 {"users/permissions", "users.objectpermission"},
+	"netbox_platform":                   {"dcim/platforms", "dcim.platform"},
+	"netbox_power_feed":                 {"dcim/power-feeds", "dcim.powerfeed"},
+	"netbox_power_panel":                {"dcim/power-panels", "dcim.powerpanel"},
+	"netbox_prefix":                     {"ipam/prefixes", "ipam.prefix"},
+	"netbox_rack":                       {"dcim/racks", "dcim.rack"},
+	"netbox_rack_reservation":           {"dcim/rack-reservations", "dcim.rackreservation"},
+	"netbox_rack_role":                  {"dcim/rack-roles", "dcim.rackrole"},
+	"netbox_region":                     {"dcim/regions", "dcim.region"},
+	"netbox_rir":                        {"ipam/rirs", "ipam.rir"},
+	"netbox_route_target":               {"ipam/route-targets", "ipam.routetarget"},
+	"netbox_service":                    {"ipam/services", "ipam.service"},
+	"netbox_site":                       {"dcim/sites", "dcim.site"},
+	"netbox_site_group":                 {"dcim/site-groups", "dcim.sitegroup"},
+	"netbox_tag":                        {"extras/tags", "extras.tag"},
+	"netbox_tenant":                     {"tenancy/tenants", "tenancy.tenant"},
+	"netbox_tenant_group":               {"tenancy/tenant-groups", "tenancy.tenantgroup"},
+	"netbox_token":                      {"users/tokens", "users.token"},
+	"netbox_user":                       {"users/users", "users.user"},
+	"netbox_virtual_chassis":            {"dcim/virtual-chassis", "dcim.virtualchassis"},
+	"netbox_virtual_disk":               {"virtualization/virtual-disks", "virtualization.virtualdisk"},
+	"netbox_virtual_machine":            {"virtualization/virtual-machines", "virtualization.virtualmachine"},
+	"netbox_vlan":                       {"ipam/vlans", "ipam.vlan"},
+	"netbox_vlan_group":                 {"ipam/vlan-groups", "ipam.vlangroup"},
+	"netbox_vpn_tunnel":                 {"vpn/tunnels", "vpn.tunnel"},
+	"netbox_vpn_tunnel_group":           {"vpn/tunnel-groups", "vpn.tunnelgroup"},
+	"netbox_vpn_tunnel_termination":     {"vpn/tunnel-terminations", "vpn.tunneltermination"},
+	"netbox_vrf":                        {"ipam/vrfs", "ipam.vrf"},
+	"netbox_webhook":                    {"extras/webhooks", "extras.webhook"},
+}
+
+// fieldObjects maps the resources that set a field of another object, rather
+// than owning one, to the type of that object. Their ID is the ID of the
+// object.
+var fieldObjects = map[string]objectType{
+	"netbox_device_primary_ip": {"dcim/devices", "dcim.device"},
+	"netbox_primary_ip":        {"virtualization/virtual-machines", "virtualization.virtualmachine"},
+}
+
+// genericObject is the resource managing an object of any endpoint, whose type
+// depends on its path.
+const genericObject = "netbox_object"
+
+// endpointType returns the type of the objects of endpoint. Their content
+// type is only known if a typed resource manages them.
+func endpointType(endpoint string) objectType {
+	for _, types := range []map[string]objectType{objectTypes, fieldObjects} {
+		for _, typ := range types {
+			if typ.endpoint == endpoint {
+				return typ
+			}
+		}
+	}
+	return objectType{endpoint: endpoint}
+}
@@ -0,0 +1,137 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+
+	netboxclient "github.com/fbreckle/go-netbox/netbox/client"
+	"github.com/go-openapi/runtime"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	shimv2 "github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfshim/sdk-v2"
+)
+
+// contextTransport submits every API call with the context of the provider
+// operation that made it, unless the call carries its own. Most upstream
+// resources do not pass a context to the client, so without this the HTTP
+// round trippers would not see the engine logger or any other state bound to
+// the operation.
+type contextTransport struct {
+	runtime.ClientTransport
+	ctx context.Context
+}
+
+func (t contextTransport) Submit(op *runtime.ClientOperation) (interface{}, error) {
+	if op.Context == nil {
+		op.Context = t.ctx
+	}
+	return t.ClientTransport.Submit(op)
+}
+
+// withContext returns a copy of the NetBox client bound to ctx.
+func withContext(ctx context.Context, meta interface{}) interface{} {
+	api, ok := meta.(*netboxclient.NetBoxAPI)
+	if !ok {
+		return meta
+	}
+	base := api.Transport
+	if t, ok := base.(contextTransport); ok {
+		base = t.ClientTransport
+	}
+	return netboxclient.New(contextTransport{ClientTransport: base, ctx: ctx}, nil)
+}
+
+// wrapOperations rewrites the CRUD functions of every resource and data source
+// as context-aware functions that hand the context on to the client, and adds
+// the behaviour this provider layers on top of them.
+func wrapOperations(p *schema.Provider) {
+	for name, r := range p.ResourcesMap {
+		toContext(r)
+		readBackResource(name, r)
+		allocationResource(name, r)
+		goneResource(name, r)
+		rejectionResource(r)
+		journalResource(name, r)
+		adoptResource(name, r)
+		validateResource(name, r)
+		conflictResource(name, r)
+		deleteBehaviorResource(name, r)
+		readOnlyResource(name, r)
+		permissionResource(name, r)
+		bindResource(name, r)
+	}
+	for name, r := range p.DataSourcesMap {
+		toContext(r)
+		bindResource(name, r)
+	}
+}
+
+// resourceKey holds the Terraform name of the resource or data source an
+// operation runs for.
+type resourceKey struct{}
+
+func resourceFrom(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(resourceKey{}).(string)
+	return name, ok
+}
+
+// toContext replaces the legacy CRUD functions of r by context-aware ones.
+func toContext(r *schema.Resource) {
+	if r.Create != nil {
+		r.CreateContext = schema.CreateContextFunc(legacyContext(r.Create))
+	}
+	if r.Read != nil {
+		r.ReadContext = schema.ReadContextFunc(legacyContext(schema.CreateFunc(r.Read)))
+	}
+	if r.Update != nil {
+		r.UpdateContext = schema.UpdateContextFunc(legacyContext(schema.CreateFunc(r.Update)))
+	}
+	if r.Delete != nil {
+		r.DeleteContext = schema.DeleteContextFunc(legacyContext(schema.CreateFunc(r.Delete)))
+	}
+	r.Create, r.Read, r.Update, r.Delete = nil, nil, nil, nil
+}
+
+func legacyContext(f schema.CreateFunc) schema.CreateContextFunc {
+	return func(_ context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		return diag.FromErr(f(d, meta))
+	}
+}
+
+// bindResource binds the context of every operation of r to the client, and
+// records the resource it runs for.
+func bindResource(name string, r *schema.Resource) {
+	r.CreateContext = bindContext(name, r.CreateContext)
+	r.ReadContext = schema.ReadContextFunc(bindContext(name, schema.CreateContextFunc(r.ReadContext)))
+	r.UpdateContext = schema.UpdateContextFunc(bindContext(name, schema.CreateContextFunc(r.UpdateContext)))
+	r.DeleteContext = schema.DeleteContextFunc(bindContext(name, schema.CreateContextFunc(r.DeleteContext)))
+}
+
+func bindContext(name string, f schema.CreateContextFunc) schema.CreateContextFunc {
+	if f == nil {
+		return nil
+	}
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		ctx = context.WithValue(ctx, resourceKey{}, name)
+		return f(ctx, d, withContext(ctx, meta))
+	}
+}
+
+// pulumiName returns the Pulumi name of the property key of r.
+func pulumiName(r *schema.Resource, key string) string {
+	return tfbridge.TerraformToPulumiNameV2(key, shimv2.NewSchemaMap(r.Schema), nil)
+}
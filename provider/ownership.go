@@ -0,0 +1,582 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/cassette"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// defaultOwnershipTag is the name of the tag marking the objects of a stack,
+// unless configured otherwise.
+const defaultOwnershipTag = "pulumi:{project}/{stack}"
+
+// ownershipConfig selects how the objects a stack manages are marked.
+type ownershipConfig struct {
+	// Tag is the template of the name of the tag marking the objects of a
+	// stack. It is used unless CustomField is set.
+	Tag string
+	// CustomField is the name of a text custom field holding the project and
+	// stack owning the object.
+	CustomField string
+	// AdoptUnowned lets updates and deletes take over objects that carry no
+	// ownership marker. Resources adopting an existing object always may.
+	AdoptUnowned bool
+}
+
+func ownershipSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Mark the objects the provider creates with the stack that owns them, and refuse to update or delete objects owned by another stack or by nobody.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"tag": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Default:     defaultOwnershipTag,
+					Description: "The name of the tag marking the objects of a stack. `{project}` and `{stack}` are replaced with the project and stack names. The tag is created when missing. Defaults to `" + defaultOwnershipTag + "`.",
+				},
+				"custom_field": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "The name of a text custom field holding `<project>/<stack>` of the owning stack, used instead of a tag. The custom field must exist and apply to every managed object type.",
+				},
+				"adopt_unowned": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Allow updates and deletes of objects carrying no ownership marker, marking them as owned by the stack. Defaults to `false`.",
+				},
+			},
+		},
+	}
+}
+
+// readOwnershipConfig returns the ownership configuration, or nil when
+// objects are not marked.
+func readOwnershipConfig(data *schema.ResourceData) *ownershipConfig {
+	blocks, _ := data.Get("ownership").([]interface{})
+	if len(blocks) == 0 {
+		return nil
+	}
+	cfg := &ownershipConfig{Tag: defaultOwnershipTag}
+	// An empty block enables ownership markers with the defaults.
+	block, _ := blocks[0].(map[string]interface{})
+	if tag, _ := block["tag"].(string); tag != "" {
+		cfg.Tag = tag
+	}
+	cfg.CustomField, _ = block["custom_field"].(string)
+	cfg.AdoptUnowned, _ = block["adopt_unowned"].(bool)
+	return cfg
+}
+
+// owner returns the marker of the stack running op: the tag name, or the
+// custom field value.
+func (cfg *ownershipConfig) owner(op *operation) string {
+	if cfg.CustomField != "" {
+		return fmt.Sprintf("%s/%s", op.URN.Project(), op.URN.Stack())
+	}
+	return strings.NewReplacer("{project}", string(op.URN.Project()), "{stack}", string(op.URN.Stack())).Replace(cfg.Tag)
+}
+
+// tagPattern matches the ownership tags of every stack.
+func (cfg *ownershipConfig) tagPattern() *regexp.Regexp {
+	pattern := regexp.QuoteMeta(cfg.Tag)
+	pattern = strings.NewReplacer(`\{project\}`, ".+", `\{stack\}`, ".+").Replace(pattern)
+	return regexp.MustCompile("^" + pattern + "$")
+}
+
+// unowned lists the content types whose objects have neither tags nor custom
+// fields, so they cannot carry an ownership marker.
+var unowned = map[string]bool{
+	"extras.customfield":          true,
+	"extras.customfieldchoiceset": true,
+	"extras.tag":                  true,
+	"users.objectpermission":      true,
+	"users.token":                 true,
+	"users.user":                  true,
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+func slugify(name string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// tagSlug returns the slug of the ownership tag name. Names differing only in
+// punctuation slugify alike, so a hash of the name keeps their slugs apart.
+// The result fits the 100 characters NetBox allows.
+func tagSlug(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	slug := slugify(name)
+	if len(slug) > 90 {
+		slug = strings.TrimRight(slug[:90], "-")
+	}
+	return slug + "-" + hex.EncodeToString(sum[:4])
+}
+
+// tagCache remembers the tags known to exist, so each tag the provider adds
+// to objects is looked up and created once. Lookups and creations of a tag
+// are serialized, since resources are created in parallel.
+type tagCache struct {
+	mu   sync.Mutex
+	tags map[string]*sync.Mutex
+	// known holds the names of the tags known to exist.
+	known sync.Map
+}
+
+// tagCall makes an API call to the tag list endpoint, failing with an
+// *apiError on an error status.
+type tagCall func(method string, query url.Values, body interface{}) (map[string]interface{}, error)
+
+// ensure creates the tag named name, with the slug tagSlug returns, unless it
+// exists. A creation NetBox rejects as a duplicate, as when another provider
+// process created the tag first, succeeds if the tag is then found.
+func (c *tagCache) ensure(name, description string, call tagCall) error {
+	if _, ok := c.known.Load(name); ok {
+		return nil
+	}
+	c.mu.Lock()
+	if c.tags == nil {
+		c.tags = map[string]*sync.Mutex{}
+	}
+	lock := c.tags[name]
+	if lock == nil {
+		lock = &sync.Mutex{}
+		c.tags[name] = lock
+	}
+	c.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := c.known.Load(name); ok {
+		return nil
+	}
+	exists := func() (bool, error) {
+		res, err := call(http.MethodGet, url.Values{"name": {name}}, nil)
+		if err != nil {
+			return false, err
+		}
+		count, _ := res["count"].(float64)
+		return count > 0, nil
+	}
+	found, err := exists()
+	if err != nil {
+		return err
+	}
+	if !found {
+		tag := map[string]interface{}{"name": name, "slug": tagSlug(name)}
+		if description != "" {
+			tag["description"] = description
+		}
+		_, err := call(http.MethodPost, nil, tag)
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest {
+			if found, _ = exists(); !found {
+				return fmt.Errorf("creating tag %q: %w", name, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("creating tag %q: %w", name, err)
+		}
+	}
+	c.known.Store(name, true)
+	return nil
+}
+
+// ownershipTransport marks the objects written by a resource with the owning
+// stack, and checks the mark before the object is updated or deleted. The
+// marks are removed from every response, so they never show up as a diff.
+type ownershipTransport struct {
+	next   http.RoundTripper
+	config *ownershipConfig
+	tags   *regexp.Regexp
+	// created holds the tags known to exist.
+	created *tagCache
+}
+
+func newOwnershipTransport(next http.RoundTripper, cfg *ownershipConfig, created *tagCache) *ownershipTransport {
+	return &ownershipTransport{next: next, config: cfg, tags: cfg.tagPattern(), created: created}
+}
+
+func (t *ownershipTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	op, ok := operationFrom(r.Context())
+	name, _ := resourceFrom(r.Context())
+	typ, managed := objectTypes[name]
+	if name == genericObject {
+		typ, managed = endpointType(objectEndpoint(apiPath(r.URL.Path))), true
+	}
+	if !ok || op.URN == "" || !managed || unowned[typ.contentType] {
+		return t.strip(t.next.RoundTrip(r))
+	}
+
+	path := apiPath(r.URL.Path)
+	id, isObject := strings.CutPrefix(path, typ.endpoint+"/")
+	isObject = isObject && id != "" && !strings.Contains(id, "/")
+	owned := path == typ.endpoint || isObject || strings.Contains(path, "/available-")
+
+	ids := []string{id}
+
+	if path == typ.endpoint && (r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete) {
+		// Bulk updates and deletes name their objects in the body.
+		var err error
+		if ids, err = bodyIDs(r); err != nil {
+			return nil, err
+		}
+		isObject = len(ids) > 0
+	}
+
+	switch {
+	case !owned:
+	case r.Method == http.MethodDelete && isObject:
+		for _, id := range ids {
+			if _, err := t.checkOwner(r, op, typ.endpoint, id); err != nil {
+				return nil, err
+			}
+		}
+	case r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodPost:
+		// current holds the objects being updated, by ID. It is nil for
+		// creates.
+		var current map[string]map[string]interface{}
+		if isObject {
+			current = map[string]map[string]interface{}{}
+			for _, id := range ids {
+				obj, err := t.checkOwner(r, op, typ.endpoint, id)
+				if err != nil {
+					return nil, err
+				}
+				current[id] = obj
+			}
+		}
+		var err error
+		if r, err = t.mark(r, op, current, id); err != nil {
+			return nil, err
+		}
+	}
+	return t.strip(t.next.RoundTrip(r))
+}
+
+// checkOwner fails unless the object is owned by the stack running op, or is
+// unowned and may be adopted. It returns the object, or nil when it does not
+// exist.
+func (t *ownershipTransport) checkOwner(r *http.Request, op *operation, endpoint, id string) (map[string]interface{}, error) {
+	obj, status, err := t.get(r, apiRoot(r.URL.Path)+endpoint+"/"+id+"/")
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		// Let the operation itself report the missing object.
+		return nil, nil
+	}
+
+	want := t.config.owner(op)
+	var got string
+	if t.config.CustomField != "" {
+		fields, _ := obj["custom_fields"].(map[string]interface{})
+		got, _ = fields[t.config.CustomField].(string)
+	} else {
+		tags, _ := obj["tags"].([]interface{})
+		for _, tag := range tags {
+			tag, _ := tag.(map[string]interface{})
+			if name, _ := tag["name"].(string); t.tags.MatchString(name) {
+				got = name
+				if name == want {
+					break
+				}
+			}
+		}
+	}
+
+	verb := strings.ToLower(op.Kind)
+	switch {
+	case got == want:
+		return obj, nil
+	case got != "":
+		return nil, fmt.Errorf("refusing to %s %s %s: it is owned by %q, not by %q", verb, endpoint, id, got, want)
+	case !t.config.AdoptUnowned && !adopting(r.Context()):
+		return nil, fmt.Errorf("refusing to %s %s %s: it carries no ownership marker. Set ownership.adoptUnowned to let stack %q take it over", verb, endpoint, id, want)
+	}
+	return obj, nil
+}
+
+// mark adds the ownership marker of op to a JSON request body, or to each
+// object of a bulk request. current holds the objects being updated by ID, and
+// id is the ID of the object of a single-object request.
+//
+// NetBox replaces every tag of an object with the tags of a request, so the
+// tag is added to an update leaving the tags out along with the current tags
+// of the object.
+func (t *ownershipTransport) mark(r *http.Request, op *operation, current map[string]map[string]interface{}, id string) (*http.Request, error) {
+	body, err := cassette.ReadBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &v) != nil {
+		return r, nil
+	}
+	objects := []interface{}{v}
+	if list, ok := v.([]interface{}); ok {
+		objects = list
+	}
+
+	owner := t.config.owner(op)
+	if t.config.CustomField == "" {
+		if err := t.ensureTag(r, owner); err != nil {
+			return nil, err
+		}
+	}
+	for _, obj := range objects {
+		obj, ok := obj.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := obj["tags"]; !ok && t.config.CustomField == "" && current != nil {
+			key := id
+			if refID(obj) != 0 {
+				key = fmt.Sprint(refID(obj))
+			}
+			have := current[key]
+			if have == nil {
+				continue
+			}
+			obj["tags"] = tagRefs(have["tags"])
+		}
+		if t.config.CustomField != "" {
+			fields, _ := obj["custom_fields"].(map[string]interface{})
+			if fields == nil {
+				fields = map[string]interface{}{}
+			}
+			fields[t.config.CustomField] = owner
+			obj["custom_fields"] = fields
+		} else {
+			tags, _ := obj["tags"].([]interface{})
+			if !hasTagName(tags, owner) {
+				tags = append(tags, map[string]interface{}{"name": owner, "slug": tagSlug(owner)})
+			}
+			obj["tags"] = tags
+		}
+	}
+
+	if body, err = json.Marshal(v); err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return r, nil
+}
+
+// tagRefs returns the tags of an object as NetBox accepts them in a request.
+func tagRefs(tags interface{}) []interface{} {
+	list, _ := tags.([]interface{})
+	refs := make([]interface{}, 0, len(list))
+	for _, tag := range list {
+		tag, ok := tag.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref := map[string]interface{}{}
+		for _, k := range []string{"name", "slug"} {
+			if v, ok := tag[k]; ok {
+				ref[k] = v
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func hasTagName(tags []interface{}, name string) bool {
+	for _, tag := range tags {
+		if tag, ok := tag.(map[string]interface{}); ok && tag["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureTag creates the ownership tag unless it exists.
+func (t *ownershipTransport) ensureTag(r *http.Request, name string) error {
+	tagsPath := apiRoot(r.URL.Path) + "extras/tags/"
+	return t.created.ensure(name, "Objects managed by this Pulumi stack", func(method string, query url.Values, body interface{}) (map[string]interface{}, error) {
+		var b []byte
+		if body != nil {
+			var err error
+			if b, err = json.Marshal(body); err != nil {
+				return nil, err
+			}
+		}
+		obj, _, err := t.do(r, method, tagsPath+"?"+query.Encode(), b)
+		return obj, err
+	})
+}
+
+func (t *ownershipTransport) get(r *http.Request, pathAndQuery string) (map[string]interface{}, int, error) {
+	return t.do(r, http.MethodGet, pathAndQuery, nil)
+}
+
+// do makes an API call of its own, with the credentials of r.
+func (t *ownershipTransport) do(r *http.Request, method, pathAndQuery string, body []byte) (map[string]interface{}, int, error) {
+	u := *r.URL
+	u.Path, u.RawQuery, _ = strings.Cut(pathAndQuery, "?")
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(r.Context(), method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, h := range []string{"Authorization", "Accept", "X-Request-ID"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	var obj map[string]interface{}
+	if res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(&obj); err != nil {
+			return nil, 0, fmt.Errorf("%s %s: %w", method, u.Path, err)
+		}
+	} else if res.StatusCode != http.StatusNotFound {
+		b, _ := io.ReadAll(res.Body)
+		return nil, 0, &apiError{Method: method, Path: u.Path, Status: res.StatusCode, Body: string(b)}
+	}
+	return obj, res.StatusCode, nil
+}
+
+// strip removes the ownership markers from a JSON response.
+func (t *ownershipTransport) strip(res *http.Response, err error) (*http.Response, error) {
+	if err != nil || !strings.Contains(res.Header.Get("Content-Type"), "json") {
+		return res, err
+	}
+	body, err := cassette.ReadBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return res, nil
+	}
+	obj, _ := v.(map[string]interface{})
+	items, isList := v.([]interface{})
+	if results, ok := obj["results"].([]interface{}); ok {
+		items, isList = results, true
+	}
+	if isList {
+		for _, item := range items {
+			item, _ := item.(map[string]interface{})
+			t.stripObject(item)
+		}
+	} else {
+		t.stripObject(obj)
+	}
+	if body, err = json.Marshal(v); err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Del("Content-Length")
+	return res, nil
+}
+
+func (t *ownershipTransport) stripObject(obj map[string]interface{}) {
+	if obj == nil {
+		return
+	}
+	if t.config.CustomField != "" {
+		if fields, ok := obj["custom_fields"].(map[string]interface{}); ok {
+			delete(fields, t.config.CustomField)
+		}
+		return
+	}
+	tags, ok := obj["tags"].([]interface{})
+	if !ok {
+		return
+	}
+	kept := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		if m, ok := tag.(map[string]interface{}); ok {
+			if name, _ := m["name"].(string); t.tags.MatchString(name) {
+				continue
+			}
+		}
+		kept = append(kept, tag)
+	}
+	obj["tags"] = kept
+}
+
+// bodyIDs returns the IDs of the objects listed in the body of a bulk
+// request.
+func bodyIDs(r *http.Request) ([]string, error) {
+	body, err := cassette.ReadBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var objects []map[string]interface{}
+	if json.Unmarshal(body, &objects) != nil {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		ids = append(ids, fmt.Sprint(refID(obj)))
+	}
+	return ids, nil
+}
+
+// apiRoot returns the path of the API root, ending with a slash.
+func apiRoot(path string) string {
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		return path[:i+len("/api/")]
+	}
+	return "/api/"
+}
+
+// apiPath returns the API path relative to the API root, without surrounding
+// slashes.
+func apiPath(path string) string {
+	return strings.Trim(strings.TrimPrefix(path, apiRoot(path)), "/")
+}
+
+// objectEndpoint returns the endpoint of an API path, which names either the
+// endpoint or one of its objects.
+func objectEndpoint(path string) string {
+	i := strings.LastIndex(path, "/")
+	if _, err := strconv.ParseInt(path[i+1:], 10, 64); i < 0 || err != nil {
+		return path
+	}
+	return path[:i]
+}
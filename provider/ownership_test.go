@@ -0,0 +1,356 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func ownershipProvider(t *testing.T, netbox *fakenetbox.Server, settings map[string]interface{}) *testProvider {
+	return newTestProvider(t, netbox, resource.PropertyMap{
+		"ownership": resource.NewObjectProperty(resource.NewPropertyMapFromMap(settings)),
+	})
+}
+
+func TestOwnershipTag(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := ownershipProvider(t, netbox, map[string]interface{}{})
+
+	urn := p.urn("netbox_site", "par1")
+	inputs := p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})
+	id, state := p.create(urn, inputs)
+	if tags := state["tags"]; tags.IsArray() && len(tags.ArrayValue()) > 0 {
+		t.Errorf("the ownership tag leaked into the state: %v", tags)
+	}
+
+	objectID, _ := strconv.ParseInt(id, 10, 64)
+	stored := getObject(netbox, "dcim/sites", objectID)
+	if !hasTag(stored, "pulumi:netbox/test") {
+		t.Errorf("created site is not tagged with its stack: %v", stored["tags"])
+	}
+	if tags := netbox.List("extras/tags"); len(tags) != 1 || tags[0]["slug"] != tagSlug("pulumi:netbox/test") {
+		t.Errorf("ownership tag was not created: %v", tags)
+	}
+
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("owned")
+	state = p.update(urn, id, state, p.check(urn, inputs, changed))
+	if !hasTag(getObject(netbox, "dcim/sites", objectID), "pulumi:netbox/test") {
+		t.Errorf("updated site lost its ownership tag")
+	}
+	p.delete(urn, id, state)
+}
+
+func TestOwnershipConflicts(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	other := netbox.Create("dcim/sites", map[string]interface{}{
+		"name": "other", "slug": "other", "status": "active",
+		"tags": []interface{}{map[string]interface{}{"name": "pulumi:netbox/prod", "slug": "pulumi-netbox-prod"}},
+	})
+	unowned := netbox.Create("dcim/sites", map[string]interface{}{"name": "unowned", "slug": "unowned", "status": "active"})
+
+	p := ownershipProvider(t, netbox, map[string]interface{}{})
+	urn := p.urn("netbox_site", "imported")
+	for id, want := range map[int64]string{
+		other:   `owned by "pulumi:netbox/prod"`,
+		unowned: "carries no ownership marker",
+	} {
+		sid := strconv.FormatInt(id, 10)
+		_, state, inputs := p.read(urn, sid, nil, nil)
+		changed := inputs.Copy()
+		changed["description"] = resource.NewStringProperty("taken")
+		if _, err := p.tryUpdate(urn, sid, state, p.check(urn, inputs, changed)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("update of site %d: got error %v, want %q", id, err, want)
+		}
+		if err := p.tryDelete(urn, sid, state); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("delete of site %d: got error %v, want %q", id, err, want)
+		}
+		if getObject(netbox, "dcim/sites", id)["description"] != nil {
+			t.Errorf("site %d was modified", id)
+		}
+	}
+
+	adopting := ownershipProvider(t, netbox, map[string]interface{}{"adoptUnowned": true})
+	sid := strconv.FormatInt(unowned, 10)
+	_, state, inputs := adopting.read(urn, sid, nil, nil)
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("adopted")
+	adopting.update(urn, sid, state, adopting.check(urn, inputs, changed))
+	if !hasTag(getObject(netbox, "dcim/sites", unowned), "pulumi:netbox/test") {
+		t.Errorf("adopted site is not tagged with its stack")
+	}
+
+	sid = strconv.FormatInt(other, 10)
+	_, state, _ = adopting.read(urn, sid, nil, nil)
+	if err := adopting.tryDelete(urn, sid, state); err == nil {
+		t.Errorf("adoptUnowned allowed deleting a site owned by another stack")
+	}
+}
+
+func TestOwnershipCustomField(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := ownershipProvider(t, netbox, map[string]interface{}{"customField": "pulumi_stack"})
+
+	urn := p.urn("netbox_site", "par1")
+	inputs := p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})
+	id, state := p.create(urn, inputs)
+	if fields := state["customFields"]; fields.IsObject() && fields.ObjectValue().HasValue("pulumi_stack") {
+		t.Errorf("the ownership field leaked into the state: %v", fields)
+	}
+	objectID, _ := strconv.ParseInt(id, 10, 64)
+	fields, _ := getObject(netbox, "dcim/sites", objectID)["custom_fields"].(map[string]interface{})
+	if fields["pulumi_stack"] != "netbox/test" {
+		t.Errorf("created site custom fields %v, want pulumi_stack=netbox/test", fields)
+	}
+
+	p.delete(urn, id, state)
+
+	other := strconv.FormatInt(netbox.Create("dcim/sites", map[string]interface{}{
+		"name": "other", "slug": "other", "status": "active",
+		"custom_fields": map[string]interface{}{"pulumi_stack": "netbox/prod"},
+	}), 10)
+	_, state, _ = p.read(urn, other, nil, nil)
+	if err := p.tryDelete(urn, other, state); err == nil || !strings.Contains(err.Error(), `owned by "netbox/prod"`) {
+		t.Errorf("delete of a site owned by another stack: got error %v", err)
+	}
+}
+
+func getObject(netbox *fakenetbox.Server, endpoint string, id int64) map[string]interface{} {
+	obj, _ := netbox.Get(endpoint, id)
+	return obj
+}
+
+func hasTag(obj map[string]interface{}, name string) bool {
+	tags, _ := obj["tags"].([]interface{})
+	for _, tag := range tags {
+		if tag, ok := tag.(map[string]interface{}); ok && tag["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOwnershipBulk(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	prefix := netbox.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.5.0.0/24", "status": "active"})
+	p := ownershipProvider(t, netbox, map[string]interface{}{})
+
+	urn := p.urn("netbox_available_ip_address", "pool")
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"prefixId": float64(prefix), "count": 3.0,
+	}))
+	id, state := p.create(urn, inputs)
+	ids := state["ipAddressIds"].ArrayValue()
+	for _, v := range ids {
+		if !hasTag(getObject(netbox, "ipam/ip-addresses", int64(v.NumberValue())), "pulumi:netbox/test") {
+			t.Errorf("allocated address %v is not tagged with its stack", v.NumberValue())
+		}
+	}
+
+	// A bulk delete is refused when any object belongs to another stack.
+	other := netbox.Create("ipam/ip-addresses", map[string]interface{}{
+		"address": "10.5.0.99/24", "status": "active",
+		"tags": []interface{}{map[string]interface{}{"name": "pulumi:netbox/other", "slug": "pulumi-netbox-other"}},
+	})
+	state = state.Copy()
+	state["ipAddressIds"] = resource.NewArrayProperty([]resource.PropertyValue{ids[0], ids[1], resource.NewNumberProperty(float64(other))})
+	if err := p.tryDelete(urn, id, state); err == nil || !strings.Contains(err.Error(), `owned by "pulumi:netbox/other"`) {
+		t.Errorf("delete of another stack's address: got error %v", err)
+	}
+}
+
+func TestOwnershipKeepsTags(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	owner := map[string]interface{}{"name": "pulumi:netbox/test", "slug": tagSlug("pulumi:netbox/test")}
+	site := netbox.Create("dcim/sites", map[string]interface{}{
+		"name": "par1", "slug": "par1", "status": "active",
+		"tags": []interface{}{owner, map[string]interface{}{"name": "backbone", "slug": "backbone"}},
+	})
+
+	// A PATCH leaving the tags out must not replace them with the marker.
+	trans := newOwnershipTransport(http.DefaultTransport, &ownershipConfig{Tag: defaultOwnershipTag}, &tagCache{})
+	trans.created.known.Store("pulumi:netbox/test", true)
+	ctx := ownershipContext("Update", "par1")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPatch, netbox.URL+"/api/dcim/sites/"+strconv.FormatInt(site, 10)+"/", strings.NewReader(`{"description":"edge"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := trans.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	stored := getObject(netbox, "dcim/sites", site)
+	if !hasTag(stored, "backbone") || !hasTag(stored, "pulumi:netbox/test") {
+		t.Errorf("tags after a PATCH without tags: %v", stored["tags"])
+	}
+	if tags, _ := stored["tags"].([]interface{}); len(tags) != 2 {
+		t.Errorf("tags after a PATCH without tags: %v", tags)
+	}
+}
+
+// ownershipContext returns the context of an operation of kind on a site.
+func ownershipContext(kind, name string) context.Context {
+	op := &operation{Kind: kind, URN: resource.NewURN("test", "netbox", "", "netbox:dcim/site:Site", name)}
+	return context.WithValue(context.WithValue(context.Background(), operationKey{}, op), resourceKey{}, "netbox_site")
+}
+
+func TestOwnershipTagCreatedOnce(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	trans := newOwnershipTransport(http.DefaultTransport, &ownershipConfig{Tag: defaultOwnershipTag}, &tagCache{})
+
+	// Sites created in parallel before the tag exists create it once.
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("site%d", i)
+			body := fmt.Sprintf(`{"name":%q,"slug":%q,"status":"active"}`, name, name)
+			req, _ := http.NewRequestWithContext(ownershipContext("Create", name), http.MethodPost, netbox.URL+"/api/dcim/sites/", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := trans.RoundTrip(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			res.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if tags := netbox.List("extras/tags"); len(tags) != 1 {
+		t.Errorf("ownership tags created: %v", tags)
+	}
+}
+
+func TestOwnershipTagCreatedElsewhere(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	// Another provider process creates the tag between the lookup and the
+	// creation, so NetBox rejects the creation as a duplicate.
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/extras/tags/") {
+			return false
+		}
+		netbox.Create("extras/tags", map[string]interface{}{"name": "pulumi:netbox/test", "slug": tagSlug("pulumi:netbox/test")})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"name": ["tag with this name already exists."]}`))
+		return true
+	})
+	trans := newOwnershipTransport(http.DefaultTransport, &ownershipConfig{Tag: defaultOwnershipTag}, &tagCache{})
+
+	req, _ := http.NewRequestWithContext(ownershipContext("Create", "par1"), http.MethodPost, netbox.URL+"/api/dcim/sites/", strings.NewReader(`{"name":"par1","slug":"par1","status":"active"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := trans.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("site creation: %s", res.Status)
+	}
+}
+
+func TestOwnershipSkipsUntaggedModels(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := ownershipProvider(t, netbox, map[string]interface{}{})
+
+	// Tags have no tags of their own, so NetBox would drop the marker.
+	urn := p.urn("netbox_tag", "backbone")
+	inputs := p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("backbone")})
+	id, state := p.create(urn, inputs)
+	objectID, _ := strconv.ParseInt(id, 10, 64)
+	if tags, ok := getObject(netbox, "extras/tags", objectID)["tags"]; ok && len(tags.([]interface{})) > 0 {
+		t.Errorf("tag was marked with its stack: %v", tags)
+	}
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("core links")
+	state = p.update(urn, id, state, p.check(urn, inputs, changed))
+	p.delete(urn, id, state)
+}
+
+func TestOwnershipGenericObject(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	other := netbox.Create("dcim/sites", map[string]interface{}{
+		"name": "other", "slug": "other", "status": "active",
+		"tags": []interface{}{map[string]interface{}{"name": "pulumi:netbox/prod", "slug": "pulumi-netbox-prod"}},
+	})
+	p := ownershipProvider(t, netbox, map[string]interface{}{})
+
+	// A generic object of a typed endpoint is checked like the typed resource.
+	urn := p.urn("netbox_object", "site")
+	sid := strconv.FormatInt(other, 10)
+	_, state, inputs := p.read(urn, "dcim/sites/"+sid, nil, nil)
+	changed := inputs.Copy()
+	changed["body"] = resource.NewStringProperty(`{"description":"taken"}`)
+	want := `owned by "pulumi:netbox/prod"`
+	if _, err := p.tryUpdate(urn, sid, state, p.check(urn, inputs, changed)); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("update: got error %v, want %q", err, want)
+	}
+	if err := p.tryDelete(urn, sid, state); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("delete: got error %v, want %q", err, want)
+	}
+	if getObject(netbox, "dcim/sites", other)["description"] != nil {
+		t.Errorf("site was modified")
+	}
+
+	// The objects of plugin endpoints are marked too.
+	urn = p.urn("netbox_object", "zone")
+	id, state := p.create(urn, p.check(urn, nil, resource.PropertyMap{
+		"path": resource.NewStringProperty("plugins/netbox-dns/zones"),
+		"body": resource.NewStringProperty(`{"name":"example.com"}`),
+	}))
+	objectID, _ := strconv.ParseInt(id, 10, 64)
+	if !hasTag(getObject(netbox, "plugins/netbox-dns/zones", objectID), "pulumi:netbox/test") {
+		t.Errorf("created zone is not tagged with its stack")
+	}
+	if strings.Contains(state["object"].StringValue(), "pulumi:netbox/test") {
+		t.Errorf("the ownership tag leaked into the object: %s", state["object"].StringValue())
+	}
+	p.delete(urn, id, state)
+}
+
+func TestOwnershipTagSlugs(t *testing.T) {
+	a, b := tagSlug("pulumi:a/b-c"), tagSlug("pulumi:a-b/c")
+	if a == b {
+		t.Errorf("stacks a/b-c and a-b/c share the tag slug %q", a)
+	}
+	if long := tagSlug("pulumi:" + strings.Repeat("x", 200)); len(long) > 100 {
+		t.Errorf("slug of a long tag has %d characters", len(long))
+	}
+}
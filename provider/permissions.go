@@ -0,0 +1,261 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The ways the permissions of the API token are checked during the plan.
+const (
+	permissionCheckOff   = "off"
+	permissionCheckWarn  = "warn"
+	permissionCheckError = "error"
+)
+
+var permissionCheckModes = []string{permissionCheckOff, permissionCheckWarn, permissionCheckError}
+
+// permissionActions are the object permission actions each planned change
+// needs.
+var permissionActions = map[string][]string{
+	"create":  {"add"},
+	"update":  {"change"},
+	"replace": {"add", "delete"},
+	"delete":  {"delete"},
+	// A delete setting the status of the object changes it instead.
+	"status change": {"change"},
+}
+
+// tokenPermissions are the effective object permissions of the API token.
+type tokenPermissions struct {
+	writeEnabled bool
+	// superuser is set if the user of the token holds every permission.
+	superuser bool
+	// actions holds the allowed actions by object type.
+	actions map[string]map[string]bool
+}
+
+func (p *tokenPermissions) allows(objectType, action string) bool {
+	return p.writeEnabled && (p.superuser || p.actions[objectType][action])
+}
+
+// permissionCache loads the token permissions once per provider.
+type permissionCache struct {
+	once        sync.Once
+	permissions *tokenPermissions
+	err         error
+}
+
+func (c *permissionCache) get(ctx context.Context, meta interface{}, token string) (*tokenPermissions, error) {
+	c.once.Do(func() {
+		c.permissions, c.err = loadPermissions(ctx, meta, token)
+		if c.err != nil {
+			warnf(ctx, "Could not check the permissions of the NetBox API token: %v", c.err)
+		}
+	})
+	return c.permissions, c.err
+}
+
+// loadPermissions reads the object permissions granted to the user of the
+// token, directly or through its groups, from the users API. Superusers hold
+// every permission without being granted any.
+func loadPermissions(ctx context.Context, meta interface{}, token string) (*tokenPermissions, error) {
+	res, err := apiCall(ctx, meta, http.MethodGet, "users/tokens/", url.Values{"key": {token}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := res.(map[string]interface{})["results"].([]interface{})
+	if len(results) != 1 {
+		return nil, fmt.Errorf("the token is not listed by the users API")
+	}
+	tok, _ := results[0].(map[string]interface{})
+	userID := refID(tok["user"])
+	writeEnabled, _ := tok["write_enabled"].(bool)
+
+	res, err = apiCall(ctx, meta, http.MethodGet, fmt.Sprintf("users/users/%d/", userID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	user, _ := res.(map[string]interface{})
+	perms := &tokenPermissions{writeEnabled: writeEnabled, actions: map[string]map[string]bool{}}
+	if perms.superuser, _ = user["is_superuser"].(bool); perms.superuser {
+		return perms, nil
+	}
+	groups := map[int64]bool{}
+	list, _ := user["groups"].([]interface{})
+	for _, g := range list {
+		groups[refID(g)] = true
+	}
+
+	permissions, err := listObjects(ctx, meta, "users/permissions/", nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, perm := range permissions {
+		if enabled, ok := perm["enabled"].(bool); ok && !enabled {
+			continue
+		}
+		if !grants(perm, userID, groups) {
+			continue
+		}
+		types, _ := perm["object_types"].([]interface{})
+		actions, _ := perm["actions"].([]interface{})
+		for _, t := range types {
+			t, _ := t.(string)
+			if perms.actions[t] == nil {
+				perms.actions[t] = map[string]bool{}
+			}
+			for _, a := range actions {
+				a, _ := a.(string)
+				perms.actions[t][a] = true
+			}
+		}
+	}
+	return perms, nil
+}
+
+// grants reports whether an object permission applies to the user.
+func grants(perm map[string]interface{}, userID int64, groups map[int64]bool) bool {
+	users, _ := perm["users"].([]interface{})
+	for _, u := range users {
+		if refID(u) == userID {
+			return true
+		}
+	}
+	list, _ := perm["groups"].([]interface{})
+	for _, g := range list {
+		if groups[refID(g)] {
+			return true
+		}
+	}
+	return false
+}
+
+// refID returns the ID of a reference, written either as an ID or as a
+// nested object.
+func refID(v interface{}) int64 {
+	if m, ok := v.(map[string]interface{}); ok {
+		v = m["id"]
+	}
+	if f, ok := v.(float64); ok {
+		return int64(f)
+	}
+	return 0
+}
+
+// permissionResource checks, during the plan of r, that the API token may
+// make the planned change. The engine does not plan deletes with the
+// provider, so those are checked when they are applied, before anything is
+// sent, against what the delete behaviour of the resource does: nothing,
+// changing the status of the object, or deleting it.
+func permissionResource(name string, r *schema.Resource) {
+	objectType := fieldObjects[name].contentType
+	if typ, ok := objectTypes[name]; ok {
+		objectType = typ.contentType
+	}
+	// The type of generic objects depends on their path, and is only known
+	// for the endpoints of typed resources.
+	typeOf := func(get func(string) interface{}) string {
+		if name != genericObject {
+			return objectType
+		}
+		return endpointType(endpointPath(get("path").(string))).contentType
+	}
+	if objectType == "" && name != genericObject {
+		return
+	}
+
+	customize := r.CustomizeDiff
+	r.CustomizeDiff = func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		verb, _ := plannedChange(r, d)
+		if err := checkPermissions(ctx, name, typeOf(d.Get), verb, meta); err != nil {
+			return err
+		}
+		if customize == nil {
+			return nil
+		}
+		return customize(ctx, d, meta)
+	}
+
+	del := r.DeleteContext
+	r.DeleteContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		verb := "delete"
+		switch deleteBehavior(r, d, meta) {
+		case retainObject:
+			return del(ctx, d, meta)
+		case statusObject:
+			verb = "status change"
+		}
+		if err := checkPermissions(ctx, name, typeOf(d.Get), verb, meta); err != nil {
+			return diag.FromErr(err)
+		}
+		return del(ctx, d, meta)
+	}
+}
+
+// checkPermissions reports a planned change of verb the API token may not
+// make, failing with an error in error mode and warning otherwise.
+func checkPermissions(ctx context.Context, name, objectType, verb string, meta interface{}) error {
+	cfg := configFrom(meta)
+	if cfg.PermissionCheck == "" || cfg.PermissionCheck == permissionCheckOff || cfg.ReadOnly {
+		return nil
+	}
+	actions := permissionActions[verb]
+	if len(actions) == 0 || objectType == "" {
+		return nil
+	}
+	if _, ok := fieldObjects[name]; ok {
+		// Setting a field of another object only changes it.
+		actions = []string{"change"}
+	}
+
+	perms, err := cfg.permissions.get(ctx, meta, cfg.APIToken)
+	if err != nil {
+		// The warning is logged once, when the permissions are loaded.
+		if cfg.PermissionCheck == permissionCheckError {
+			return fmt.Errorf("could not check the permissions of the NetBox API token for the planned %s of %s: %w",
+				verb, describeResource(ctx, name), err)
+		}
+		return nil
+	}
+	var missing []string
+	for _, action := range actions {
+		if !perms.allows(objectType, action) {
+			missing = append(missing, action)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("the NetBox API token cannot %s %s objects, which the planned %s of %s needs",
+		strings.Join(missing, " or "), objectType, verb, describeResource(ctx, name))
+	if !perms.writeEnabled {
+		msg += "; the token is not write-enabled"
+	}
+	if cfg.PermissionCheck == permissionCheckError {
+		return fmt.Errorf("%s", msg)
+	}
+	warnf(ctx, "%s", msg)
+	return nil
+}
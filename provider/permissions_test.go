@@ -0,0 +1,250 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestPermissionCheck(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	ids := fixtures(netbox)
+	group := netbox.Create("users/groups", map[string]interface{}{"name": "ipam"})
+	user := netbox.Create("users/users", map[string]interface{}{"username": "deployer", "groups": []interface{}{group}})
+	netbox.Create("users/tokens", map[string]interface{}{
+		"key": "0123456789abcdef0123456789abcdef01234567", "user": user, "write_enabled": true,
+	})
+	netbox.Create("users/permissions", map[string]interface{}{
+		"name": "sites", "enabled": true, "object_types": []interface{}{"dcim.site"},
+		"actions": []interface{}{"view", "add"}, "users": []interface{}{user},
+	})
+	netbox.Create("users/permissions", map[string]interface{}{
+		"name": "ipam", "enabled": true, "object_types": []interface{}{"ipam.prefix"},
+		"actions": []interface{}{"view", "add", "change", "delete"}, "groups": []interface{}{group},
+	})
+	netbox.Create("users/permissions", map[string]interface{}{
+		"name": "devices", "enabled": false, "object_types": []interface{}{"dcim.device"},
+		"actions": []interface{}{"add"}, "users": []interface{}{user},
+	})
+
+	p := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("error")})
+	preview := func(tfName string, inputs map[string]interface{}) error {
+		urn := p.urn(tfName, "test")
+		_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+			Urn: string(urn), Properties: p.marshal(p.check(urn, nil, resource.NewPropertyMapFromMap(inputs))), Preview: true,
+		})
+		return err
+	}
+
+	if err := preview("netbox_site", map[string]interface{}{"name": "par1"}); err != nil {
+		t.Errorf("site create allowed by a user permission: %v", err)
+	}
+	if err := preview("netbox_prefix", map[string]interface{}{"prefix": "10.0.0.0/24", "status": "active"}); err != nil {
+		t.Errorf("prefix create allowed by a group permission: %v", err)
+	}
+	err := preview("netbox_device", map[string]interface{}{
+		"name": "leaf2", "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]), "siteId": float64(ids["site"]),
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot add dcim.device objects, which the planned create of urn:pulumi:test::netbox::netbox:index/device:Device::test needs") {
+		t.Errorf("device create with a disabled permission: got error %v", err)
+	}
+
+	urn := p.urn("netbox_site", "test")
+	id, _ := newTestProvider(t, netbox, nil).create(urn, p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par2")}))
+	_, state, inputs := p.read(urn, id, nil, nil)
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("changed")
+	_, err = p.server.Diff(context.Background(), &pulumirpc.DiffRequest{
+		Id: id, Urn: string(urn), Olds: p.marshal(state), News: p.marshal(p.check(urn, inputs, changed)),
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot change dcim.site objects") {
+		t.Errorf("site update without change permission: got error %v", err)
+	}
+
+	// Deletes are not planned with the provider, so they are checked when
+	// applied.
+	if err := p.tryDelete(urn, id, state); err == nil || !strings.Contains(err.Error(), "cannot delete dcim.site objects, which the planned delete of "+string(urn)+" needs") {
+		t.Errorf("site delete without delete permission: got error %v", err)
+	}
+	objectID, _ := strconv.ParseInt(id, 10, 64)
+	if _, ok := netbox.Get("dcim/sites", objectID); !ok {
+		t.Errorf("site was deleted without delete permission")
+	}
+	prefixURN := p.urn("netbox_prefix", "test")
+	prefixID, prefixState := p.create(prefixURN, p.check(prefixURN, nil, resource.PropertyMap{
+		"prefix": resource.NewStringProperty("10.0.1.0/24"), "status": resource.NewStringProperty("active"),
+	}))
+	p.delete(prefixURN, prefixID, prefixState)
+
+	warn := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("warn")})
+	deviceURN := warn.urn("netbox_device", "test")
+	_, err = warn.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn: string(deviceURN), Preview: true, Properties: warn.marshal(warn.check(deviceURN, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+			"name": "leaf2", "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]), "siteId": float64(ids["site"]),
+		}))),
+	})
+	if err != nil {
+		t.Errorf("warn mode failed the plan: %v", err)
+	}
+}
+
+func TestPermissionCheckGenericObject(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	user := netbox.Create("users/users", map[string]interface{}{"username": "deployer"})
+	netbox.Create("users/tokens", map[string]interface{}{
+		"key": "0123456789abcdef0123456789abcdef01234567", "user": user, "write_enabled": true,
+	})
+
+	p := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("error")})
+	preview := func(name, path, body string) error {
+		urn := p.urn("netbox_object", name)
+		_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+			Urn: string(urn), Preview: true, Properties: p.marshal(p.check(urn, nil, resource.PropertyMap{
+				"path": resource.NewStringProperty(path), "body": resource.NewStringProperty(body),
+			})),
+		})
+		return err
+	}
+
+	// The objects of typed endpoints need the permissions of their type.
+	if err := preview("site", "dcim/sites", `{"name":"par1","slug":"par1"}`); err == nil || !strings.Contains(err.Error(), "cannot add dcim.site objects") {
+		t.Errorf("site create without add permission: got error %v", err)
+	}
+	// The type of plugin objects is unknown, so they are not checked.
+	if err := preview("zone", "plugins/netbox-dns/zones", `{"name":"example.com"}`); err != nil {
+		t.Errorf("plugin object create: %v", err)
+	}
+}
+
+func TestPermissionCheckUnreadable(t *testing.T) {
+	// The token is not listed by the users API, so its permissions are
+	// unknown.
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+
+	for mode, fails := range map[string]bool{"warn": false, "error": true} {
+		p := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty(mode)})
+		urn := p.urn("netbox_site", "test")
+		_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+			Urn: string(urn), Preview: true,
+			Properties: p.marshal(p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})),
+		})
+		if fails && (err == nil || !strings.Contains(err.Error(), "could not check the permissions of the NetBox API token")) {
+			t.Errorf("%s mode: got error %v, want the permissions to be unreadable", mode, err)
+		}
+		if !fails && err != nil {
+			t.Errorf("%s mode failed the plan: %v", mode, err)
+		}
+	}
+}
+
+func TestPermissionCheckSuperuser(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	user := netbox.Create("users/users", map[string]interface{}{"username": "admin", "is_superuser": true})
+	netbox.Create("users/tokens", map[string]interface{}{
+		"key": "0123456789abcdef0123456789abcdef01234567", "user": user, "write_enabled": true,
+	})
+
+	p := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("error")})
+	urn := p.urn("netbox_site", "test")
+	_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn: string(urn), Preview: true,
+		Properties: p.marshal(p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})),
+	})
+	if err != nil {
+		t.Errorf("superuser create without object permissions: %v", err)
+	}
+}
+
+func TestPermissionCheckUserUnreadable(t *testing.T) {
+	// The groups of the user cannot be read, so the permissions granted
+	// through them are unknown.
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	user := netbox.Create("users/users", map[string]interface{}{"username": "deployer"})
+	netbox.Create("users/tokens", map[string]interface{}{
+		"key": "0123456789abcdef0123456789abcdef01234567", "user": user, "write_enabled": true,
+	})
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/api/users/users/") {
+			return false
+		}
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	})
+
+	p := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("error")})
+	urn := p.urn("netbox_site", "test")
+	_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn: string(urn), Preview: true,
+		Properties: p.marshal(p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})),
+	})
+	if err == nil || !strings.Contains(err.Error(), "could not check the permissions of the NetBox API token") {
+		t.Errorf("got error %v, want the permissions to be unreadable", err)
+	}
+}
+
+func TestPermissionCheckDeleteBehavior(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	user := netbox.Create("users/users", map[string]interface{}{"username": "deployer"})
+	netbox.Create("users/tokens", map[string]interface{}{
+		"key": "0123456789abcdef0123456789abcdef01234567", "user": user, "write_enabled": true,
+	})
+	sites := netbox.Create("users/permissions", map[string]interface{}{
+		"name": "sites", "enabled": true, "object_types": []interface{}{"dcim.site"},
+		"actions": []interface{}{"view", "add", "change"}, "users": []interface{}{user},
+	})
+
+	// Retaining a site needs no permission, and retiring it only changes it.
+	p := newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("error")})
+	for _, behavior := range []string{"retain", "status"} {
+		urn := p.urn("netbox_site", behavior)
+		id, state := p.create(urn, p.check(urn, nil, resource.PropertyMap{
+			"name":           resource.NewStringProperty(behavior),
+			"deleteBehavior": resource.NewStringProperty(behavior),
+		}))
+		if err := p.tryDelete(urn, id, state); err != nil {
+			t.Errorf("delete with the %s behaviour: %v", behavior, err)
+		}
+	}
+
+	netbox.Delete("users/permissions", sites)
+	netbox.Create("users/permissions", map[string]interface{}{
+		"name": "sites", "enabled": true, "object_types": []interface{}{"dcim.site"},
+		"actions": []interface{}{"view", "add", "delete"}, "users": []interface{}{user},
+	})
+	p = newTestProvider(t, netbox, resource.PropertyMap{"permissionCheck": resource.NewStringProperty("error")})
+	urn := p.urn("netbox_site", "retired")
+	id, state := p.create(urn, p.check(urn, nil, resource.PropertyMap{
+		"name":           resource.NewStringProperty("retired"),
+		"deleteBehavior": resource.NewStringProperty("status"),
+	}))
+	if err := p.tryDelete(urn, id, state); err == nil || !strings.Contains(err.Error(), "cannot change dcim.site objects, which the planned status change of "+string(urn)+" needs") {
+		t.Errorf("status delete without change permission: got error %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cassette records the HTTP interactions between the provider and
+// NetBox to a file, and replays them later without a server.
+//
+// A cassette is a JSON document holding the interactions in the order they
+// happened. Credentials are redacted before anything is written, so cassettes
+// can be attached to bug reports and checked in as test fixtures.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces every secret written to a cassette.
+const Redacted = "REDACTED"
+
+// RedactedHeaders are never written to a cassette, or logged, verbatim.
+var RedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrftoken"}
+
+// RedactedFields lists, per API path, the JSON fields of request and response
+// bodies whose values are secrets.
+var RedactedFields = map[string][]string{
+	"/users/tokens/": {"key"},
+	"/users/users/":  {"password"},
+}
+
+// RedactBody replaces the values of the RedactedFields of path anywhere in
+// body. Bodies of those paths that are not JSON are redacted entirely, since
+// they cannot be inspected.
+func RedactBody(path string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	for suffix, fields := range RedactedFields {
+		if strings.Contains(path, suffix) {
+			body = redactJSON(body, fields)
+		}
+	}
+	return body
+}
+
+func redactJSON(body []byte, fields []string) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []byte(Redacted)
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				for _, f := range fields {
+					if k == f {
+						v[k] = Redacted
+					}
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+	out, err := json.Marshal(v)
+	if err != nil {
+		return []byte(Redacted)
+	}
+	return out
+}
+
+// Cassette is the on-disk format of a recording.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and the response NetBox gave to it.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request. URL holds the path and query only, so a
+// cassette can be replayed against any server URL.
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Load reads a cassette from path.
+func Load(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("reading cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// cassetteEnd closes the interactions of a cassette being recorded. Each new
+// interaction is written over it, so the file stays a valid cassette after
+// every request without being rewritten.
+const cassetteEnd = "\n  ]\n}\n"
+
+// Recorder is an http.RoundTripper that forwards requests to Next and appends
+// every interaction to the cassette at Path.
+type Recorder struct {
+	Path string
+	Next http.RoundTripper
+	// Headers are the names of headers redacted in addition to the
+	// credentials NetBox itself uses.
+	Headers []string
+
+	secrets []string
+	mu      sync.Mutex
+	file    *os.File
+	count   int
+}
+
+// NewRecorder returns a Recorder writing to path. Any of secrets found in a
+// header, URL or body is replaced with Redacted.
+func NewRecorder(path string, next http.RoundTripper, secrets ...string) (*Recorder, error) {
+	r := &Recorder{Path: path, Next: next}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Fail at configure time rather than on the first request.
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating cassette %s: %w", path, err)
+	}
+	if _, err := io.WriteString(f, `{
+  "interactions": [`+cassetteEnd); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("creating cassette %s: %w", path, err)
+	}
+	r.file = f
+	return r, nil
+}
+
+// Close closes the cassette file. Interactions recorded afterwards fail.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// write appends i to the cassette file, over its closing brackets.
+func (r *Recorder) write(i Interaction) error {
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	b, err := json.MarshalIndent(i, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := "\n    "
+	if r.count > 0 {
+		sep = "," + sep
+	}
+	if _, err := r.file.Seek(-int64(len(cassetteEnd)), io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := r.file.WriteString(sep + string(b) + cassetteEnd); err != nil {
+		return err
+	}
+	r.count++
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := ReadBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := ReadBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	i := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     r.redact(req.URL.RequestURI()),
+			Headers: r.redactHeaders(req.Header),
+			Body:    r.redact(string(RedactBody(req.URL.Path, reqBody))),
+		},
+		Response: Response{
+			Status:  res.StatusCode,
+			Headers: r.redactHeaders(res.Header),
+			Body:    r.redact(string(RedactBody(req.URL.Path, resBody))),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.write(i); err != nil {
+		return nil, fmt.Errorf("writing cassette %s: %w", r.Path, err)
+	}
+	return res, nil
+}
+
+func (r *Recorder) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+func (r *Recorder) redactHeaders(h http.Header) http.Header {
+	out := http.Header{}
+	for k, values := range h {
+		for _, v := range values {
+			out.Add(k, r.redact(v))
+		}
+	}
+	for _, k := range append(RedactedHeaders, r.Headers...) {
+		if out.Get(k) != "" {
+			out.Set(k, Redacted)
+		}
+	}
+	return out
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette
+// instead of a server.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer returns a Replayer serving the cassette at path.
+func NewReplayer(path string) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{cassette: c, used: make([]bool, len(c.Interactions))}, nil
+}
+
+// RoundTrip implements http.RoundTripper. Interactions are consumed in order:
+// the first unused one with the same method, URL and body wins, falling back
+// to the first unused one with the same method and URL.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ReadBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	url := req.URL.RequestURI()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Request.Method != req.Method || in.Request.URL != url {
+			continue
+		}
+		if sameBody(in.Request.Body, string(body)) {
+			match = i
+			break
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("cassette has no recorded response for %s %s", req.Method, url)
+	}
+	r.used[match] = true
+
+	recorded := r.cassette.Interactions[match].Response
+	header := recorded.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// sameBody compares two request bodies, ignoring JSON formatting.
+func sameBody(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, []byte(a)) != nil || json.Compact(&cb, []byte(b)) != nil {
+		return false
+	}
+	return ca.String() == cb.String()
+}
+
+// ReadBody reads body and replaces it with an equivalent reader, so a
+// transport can inspect a request or response and still pass it on.
+func ReadBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassette
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderRedacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "sessionid=abc")
+		io.WriteString(w, `{"key":"s3cr3t","echo":`+r.URL.Query().Get("n")+`}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(path, http.DefaultTransport, "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: rec}
+	for _, n := range []string{"1", "2"} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/users/tokens/?n="+n, strings.NewReader(`{"token":"s3cr3t"}`))
+		req.Header.Set("Authorization", "Token s3cr3t")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(body), "s3cr3t") {
+			t.Errorf("recorder altered the live response: %s", body)
+		}
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Fatalf("recorded %d interactions, want 2", len(c.Interactions))
+	}
+	in := c.Interactions[0]
+	if in.Request.URL != "/api/users/tokens/?n=1" {
+		t.Errorf("URL = %q", in.Request.URL)
+	}
+	for _, s := range []string{in.Request.Headers.Get("Authorization"), in.Request.Body, in.Response.Body, in.Response.Headers.Get("Set-Cookie")} {
+		if strings.Contains(s, "s3cr3t") || strings.Contains(s, "abc") {
+			t.Errorf("secret written to cassette: %q", s)
+		}
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	c := &Cassette{Interactions: []Interaction{
+		{Request{Method: "GET", URL: "/api/dcim/sites/1/"}, Response{Status: 200, Body: `{"id":1,"name":"a"}`}},
+		{Request{Method: "PATCH", URL: "/api/dcim/sites/1/", Body: `{"name":"b"}`}, Response{Status: 200, Body: `{"id":1,"name":"b"}`}},
+		{Request{Method: "GET", URL: "/api/dcim/sites/1/"}, Response{Status: 200, Body: `{"id":1,"name":"b"}`}},
+	}}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: r}
+
+	get := func() string {
+		res, err := client.Get("http://netbox.invalid/api/dcim/sites/1/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return string(b)
+	}
+
+	if got := get(); got != `{"id":1,"name":"a"}` {
+		t.Errorf("first GET = %s", got)
+	}
+	req, _ := http.NewRequest(http.MethodPatch, "http://netbox.invalid/api/dcim/sites/1/", strings.NewReader(`{ "name": "b" }`))
+	if res, err := client.Do(req); err != nil || res.StatusCode != 200 {
+		t.Fatalf("PATCH = %v, %v", res, err)
+	}
+	if got := get(); got != `{"id":1,"name":"b"}` {
+		t.Errorf("second GET = %s", got)
+	}
+	if _, err := client.Get("http://netbox.invalid/api/dcim/sites/1/"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("exhausted cassette returned %v", err)
+	}
+}
+
+func TestRecorderRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		switch r.URL.Path {
+		case "/api/users/tokens/":
+			io.WriteString(w, `{"id":1,"user":{"id":2,"username":"ci"},"key":"0123456789abcdef","write_enabled":true}`)
+		case "/api/users/users/":
+			io.WriteString(w, `{"id":2,"username":"ci"}`)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(path, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Close()
+	client := &http.Client{Transport: rec}
+	for i, call := range []struct{ path, body string }{
+		{"/api/users/users/", `{"username":"ci","password":"hunter2"}`},
+		{"/api/users/tokens/", `{"user":2,"key":"0123456789abcdef","write_enabled":true}`},
+	} {
+		res, err := client.Post(server.URL+call.path, "application/json", strings.NewReader(call.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		// The cassette is complete after every request, not only on Close.
+		c, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Interactions) != i+1 {
+			t.Fatalf("recorded %d interactions, want %d", len(c.Interactions), i+1)
+		}
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range c.Interactions {
+		for _, body := range []string{in.Request.Body, in.Response.Body} {
+			if strings.Contains(body, "hunter2") || strings.Contains(body, "0123456789abcdef") {
+				t.Errorf("secret written to cassette: %s", body)
+			}
+		}
+	}
+	token := c.Interactions[1]
+	if !strings.Contains(token.Response.Body, `"key":"REDACTED"`) || !strings.Contains(token.Response.Body, `"write_enabled":true`) {
+		t.Errorf("token response = %s", token.Response.Body)
+	}
+}
@@ -0,0 +1,428 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakenetbox
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"strconv"
+
+	"github.com/fbreckle/go-netbox/netbox/models"
+)
+
+// action serves a detail route such as /api/ipam/prefixes/{id}/available-ips/.
+type action func(s *Server, w http.ResponseWriter, r *http.Request, endpoint string, id int64)
+
+var actions = map[string]action{
+	"available-ips":      (*Server).serveAvailableIPs,
+	"available-prefixes": (*Server).serveAvailablePrefixes,
+	"available-vlans":    (*Server).serveAvailableVLANs,
+	"available-asns":     (*Server).serveAvailableASNs,
+}
+
+func (s *Server) serveAvailableIPs(w http.ResponseWriter, r *http.Request, endpoint string, id int64) {
+	parent, ok := s.objects[endpoint][id]
+	if !ok || endpoint != "ipam/prefixes" && endpoint != "ipam/ip-ranges" {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+
+	var first, last netip.Addr
+	var bits int
+	if endpoint == "ipam/prefixes" {
+		p, err := netip.ParsePrefix(fmt.Sprint(parent["prefix"]))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		p = p.Masked()
+		bits = p.Bits()
+		first, last = p.Addr(), lastAddr(p)
+		if pool, _ := parent["is_pool"].(bool); !pool && p.Bits() < p.Addr().BitLen()-1 {
+			if first.Is4() {
+				last = last.Prev()
+			}
+			first = first.Next()
+		}
+	} else {
+		start, end, ok := rangeBounds(parent)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid IP range"))
+			return
+		}
+		first, last = start.Addr(), end.Addr()
+		bits = start.Bits()
+	}
+
+	vrf := parent["vrf"]
+	used := map[netip.Addr]bool{}
+	for _, ip := range s.objects["ipam/ip-addresses"] {
+		if !sameID(ip["vrf"], vrf) {
+			continue
+		}
+		if p, err := netip.ParsePrefix(fmt.Sprint(ip["address"])); err == nil {
+			used[p.Addr()] = true
+		}
+	}
+
+	var free []netip.Addr
+	want := requestedCount(r)
+	bodies := []map[string]interface{}{}
+	many := false
+	if r.Method == http.MethodPost {
+		var err error
+		bodies, many, err = decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		want = len(bodies)
+	} else if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	for a := first; a.IsValid() && a.Compare(last) <= 0 && len(free) < want; a = a.Next() {
+		if !used[a] {
+			free = append(free, a)
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		res := []map[string]interface{}{}
+		for _, a := range free {
+			res = append(res, map[string]interface{}{
+				"family":  family(a),
+				"address": netip.PrefixFrom(a, bits).String(),
+				"vrf":     s.shapeVRF(vrf),
+			})
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	if len(free) < len(bodies) {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"detail": fmt.Sprintf("An insufficient number of IP addresses are available within %s (%d requested, %d available)",
+				display(parent), len(bodies), len(free)),
+		})
+		return
+	}
+	var created []map[string]interface{}
+	for i, body := range bodies {
+		obj := copyValue(body).(map[string]interface{})
+		obj["address"] = netip.PrefixFrom(free[i], bits).String()
+		if _, ok := obj["vrf"]; !ok {
+			obj["vrf"] = vrf
+		}
+		ipID := s.create("ipam/ip-addresses", obj)
+		created = append(created, s.objects["ipam/ip-addresses"][ipID])
+	}
+	writeObjects(w, http.StatusCreated, s.renderAll("ipam/ip-addresses", created), many)
+}
+
+func (s *Server) serveAvailablePrefixes(w http.ResponseWriter, r *http.Request, endpoint string, id int64) {
+	parent, ok := s.objects[endpoint][id]
+	if !ok || endpoint != "ipam/prefixes" {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+	p, err := netip.ParsePrefix(fmt.Sprint(parent["prefix"]))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	p = p.Masked()
+
+	vrf := parent["vrf"]
+	var used []netip.Prefix
+	for cid, child := range s.objects["ipam/prefixes"] {
+		if cid == id || !sameID(child["vrf"], vrf) {
+			continue
+		}
+		if c, err := netip.ParsePrefix(fmt.Sprint(child["prefix"])); err == nil {
+			used = append(used, c.Masked())
+		}
+	}
+	free := freeBlocks(p, used)
+
+	switch r.Method {
+	case http.MethodGet:
+		res := []map[string]interface{}{}
+		for _, b := range free {
+			res = append(res, map[string]interface{}{
+				"family": family(b.Addr()),
+				"prefix": b.String(),
+				"vrf":    s.shapeVRF(vrf),
+			})
+		}
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodPost:
+		bodies, many, err := decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var allocated []netip.Prefix
+		for _, body := range bodies {
+			length, ok := toInt(body["prefix_length"])
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+					"prefix_length": []string{"This field is required."},
+				})
+				return
+			}
+			block, ok := allocate(free, int(length))
+			if !ok {
+				writeJSON(w, http.StatusConflict, map[string]interface{}{
+					"detail": "Insufficient space is available to accommodate the requested prefix size(s)",
+				})
+				return
+			}
+			allocated = append(allocated, block)
+			free = freeBlocks(p, append(used, allocated...))
+		}
+		var created []map[string]interface{}
+		for i, body := range bodies {
+			obj := copyValue(body).(map[string]interface{})
+			delete(obj, "prefix_length")
+			obj["prefix"] = allocated[i].String()
+			if _, ok := obj["vrf"]; !ok {
+				obj["vrf"] = vrf
+			}
+			prefixID := s.create("ipam/prefixes", obj)
+			created = append(created, s.objects["ipam/prefixes"][prefixID])
+		}
+		writeObjects(w, http.StatusCreated, s.renderAll("ipam/prefixes", created), many)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveAvailableVLANs(w http.ResponseWriter, r *http.Request, endpoint string, id int64) {
+	group, ok := s.objects[endpoint][id]
+	if !ok || endpoint != "ipam/vlan-groups" {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+	first, last := int64(1), int64(4094)
+	if v, ok := toInt(group["min_vid"]); ok {
+		first = v
+	}
+	if v, ok := toInt(group["max_vid"]); ok {
+		last = v
+	}
+	used := map[int64]bool{}
+	for _, vlan := range s.objects["ipam/vlans"] {
+		if sameID(vlan["group"], id) {
+			vid, _ := toInt(vlan["vid"])
+			used[vid] = true
+		}
+	}
+	var free []int64
+	for vid := first; vid <= last; vid++ {
+		if !used[vid] {
+			free = append(free, vid)
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		res := []map[string]interface{}{}
+		for _, vid := range free {
+			if len(res) == requestedCount(r) {
+				break
+			}
+			res = append(res, map[string]interface{}{"vid": vid, "group": s.render(endpoint, group)})
+		}
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodPost:
+		bodies, many, err := decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(free) < len(bodies) {
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"detail": fmt.Sprintf("Insufficient VLANs available (%d requested, %d available)", len(bodies), len(free)),
+			})
+			return
+		}
+		var created []map[string]interface{}
+		for i, body := range bodies {
+			obj := copyValue(body).(map[string]interface{})
+			obj["vid"] = free[i]
+			obj["group"] = id
+			vlanID := s.create("ipam/vlans", obj)
+			created = append(created, s.objects["ipam/vlans"][vlanID])
+		}
+		writeObjects(w, http.StatusCreated, s.renderAll("ipam/vlans", created), many)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveAvailableASNs(w http.ResponseWriter, r *http.Request, endpoint string, id int64) {
+	asnRange, ok := s.objects[endpoint][id]
+	if !ok || endpoint != "ipam/asn-ranges" {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+	first, _ := toInt(asnRange["start"])
+	last, _ := toInt(asnRange["end"])
+	rir, _ := refID(asnRange["rir"])
+	// As in NetBox, the ASNs of other RIRs do not take numbers of the range.
+	used := map[int64]bool{}
+	for _, asn := range s.objects["ipam/asns"] {
+		if sameID(asn["rir"], rir) {
+			n, _ := toInt(asn["asn"])
+			used[n] = true
+		}
+	}
+	var free []int64
+	for n := first; n <= last && len(free) < maxFreeASNs; n++ {
+		if !used[n] {
+			free = append(free, n)
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		res := []map[string]interface{}{}
+		for _, n := range free {
+			if len(res) == requestedCount(r) {
+				break
+			}
+			res = append(res, map[string]interface{}{"asn": n, "rir": s.render("ipam/rirs", s.objects["ipam/rirs"][rir])})
+		}
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodPost:
+		bodies, many, err := decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(free) < len(bodies) {
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"detail": fmt.Sprintf("Insufficient ASNs available (%d requested, %d available)", len(bodies), len(free)),
+			})
+			return
+		}
+		var created []map[string]interface{}
+		for i, body := range bodies {
+			obj := copyValue(body).(map[string]interface{})
+			obj["asn"] = free[i]
+			obj["rir"] = rir
+			asnID := s.create("ipam/asns", obj)
+			created = append(created, s.objects["ipam/asns"][asnID])
+		}
+		writeObjects(w, http.StatusCreated, s.renderAll("ipam/asns", created), many)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// maxFreeASNs bounds the free ASNs listed, as 32-bit ranges can hold billions.
+const maxFreeASNs = 1000
+
+func (s *Server) shapeVRF(vrf interface{}) interface{} {
+	return s.shape(copyValue(vrf), reflect.TypeOf(models.NestedVRF{}), 0)
+}
+
+// freeBlocks returns the largest CIDR blocks of p that overlap none of used.
+func freeBlocks(p netip.Prefix, used []netip.Prefix) []netip.Prefix {
+	for _, u := range used {
+		if u.Bits() <= p.Bits() && u.Contains(p.Addr()) {
+			return nil
+		}
+	}
+	overlaps := false
+	for _, u := range used {
+		if p.Overlaps(u) {
+			overlaps = true
+			break
+		}
+	}
+	if !overlaps {
+		return []netip.Prefix{p}
+	}
+	lo, hi := split(p)
+	return append(freeBlocks(lo, used), freeBlocks(hi, used)...)
+}
+
+// allocate returns the first prefix of the given length inside free.
+func allocate(free []netip.Prefix, length int) (netip.Prefix, bool) {
+	for _, b := range free {
+		if b.Bits() <= length && length <= b.Addr().BitLen() {
+			return netip.PrefixFrom(b.Addr(), length), true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+func split(p netip.Prefix) (netip.Prefix, netip.Prefix) {
+	lo := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	return lo, netip.PrefixFrom(lastAddr(lo).Next(), p.Bits()+1)
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+func rangeBounds(obj map[string]interface{}) (netip.Prefix, netip.Prefix, bool) {
+	start, err1 := netip.ParsePrefix(fmt.Sprint(obj["start_address"]))
+	end, err2 := netip.ParsePrefix(fmt.Sprint(obj["end_address"]))
+	return start, end, err1 == nil && err2 == nil
+}
+
+func rangeSize(start, end netip.Prefix) int64 {
+	s := new(big.Int).SetBytes(start.Addr().AsSlice())
+	e := new(big.Int).SetBytes(end.Addr().AsSlice())
+	return new(big.Int).Sub(e, s).Int64() + 1
+}
+
+func requestedCount(r *http.Request) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		return n
+	}
+	return 50
+}
+
+func family(a netip.Addr) int {
+	if a.Is4() {
+		return 4
+	}
+	return 6
+}
+
+func sameID(a, b interface{}) bool {
+	ida, oka := refID(a)
+	idb, okb := refID(b)
+	return oka == okb && ida == idb
+}
+
+func refID(v interface{}) (int64, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		v = m["id"]
+	}
+	return toInt(v)
+}
@@ -0,0 +1,302 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakenetbox
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ignoredParams are query parameters that do not filter the result set.
+var ignoredParams = map[string]bool{
+	"limit":    true,
+	"offset":   true,
+	"ordering": true,
+	"brief":    true,
+	"fields":   true,
+	"format":   true,
+	"exclude":  true,
+}
+
+// lookups are the NetBox filter lookup suffixes the fake understands.
+var lookups = map[string]bool{
+	"n": true, "ic": true, "nic": true, "ie": true, "nie": true,
+	"isw": true, "nisw": true, "iew": true, "niew": true,
+	"gt": true, "gte": true, "lt": true, "lte": true, "empty": true,
+}
+
+// matchesQuery reports whether the rendered object obj matches every filter in
+// query. Repeated values of the same filter are OR-ed, different filters are
+// AND-ed, as in NetBox.
+func matchesQuery(obj map[string]interface{}, query url.Values) bool {
+	for key, values := range query {
+		if ignoredParams[key] {
+			continue
+		}
+		if !matchesFilter(obj, key, values) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesFilter(obj map[string]interface{}, key string, values []string) bool {
+	if key == "q" {
+		for _, v := range values {
+			if !search(obj, strings.ToLower(v)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	field, lookup := key, ""
+	if i := strings.LastIndex(key, "__"); i > 0 && lookups[key[i+2:]] {
+		field, lookup = key[:i], key[i+2:]
+	}
+
+	if ok, handled := matchesNetwork(obj, field, values); handled {
+		return ok
+	}
+
+	candidates := fieldValues(obj, field)
+	negate := false
+	if lookup == "n" || strings.HasPrefix(lookup, "n") && lookups[lookup[1:]] {
+		negate, lookup = true, strings.TrimPrefix(lookup, "n")
+	}
+
+	matched := false
+	for _, want := range values {
+		if matchesValue(candidates, want, lookup) {
+			matched = true
+			break
+		}
+	}
+	return matched != negate
+}
+
+func matchesValue(candidates []string, want, lookup string) bool {
+	if lookup == "empty" {
+		empty := len(candidates) == 0 || len(candidates) == 1 && candidates[0] == ""
+		return strconv.FormatBool(empty) == strings.ToLower(want)
+	}
+	if want == "null" {
+		return len(candidates) == 0
+	}
+	for _, have := range candidates {
+		if compare(have, want, lookup) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValues returns the string forms a filter on field can match against.
+func fieldValues(obj map[string]interface{}, field string) []string {
+	if field == "tag" {
+		field = "tags"
+	}
+	if v, ok := obj[field]; ok {
+		return scalarValues(v, false)
+	}
+	if base := strings.TrimSuffix(field, "_id"); base != field {
+		if v, ok := obj[base]; ok {
+			return scalarValues(v, true)
+		}
+	}
+	return nil
+}
+
+func scalarValues(v interface{}, idsOnly bool) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		if idsOnly {
+			if id, ok := v["id"]; ok {
+				return []string{formatScalar(id)}
+			}
+			return nil
+		}
+		var res []string
+		for _, k := range []string{"id", "slug", "name", "value"} {
+			if item, ok := v[k]; ok && item != nil {
+				res = append(res, formatScalar(item))
+			}
+		}
+		return res
+	case []interface{}:
+		var res []string
+		for _, item := range v {
+			res = append(res, scalarValues(item, idsOnly)...)
+		}
+		return res
+	}
+	return []string{formatScalar(v)}
+}
+
+func formatScalar(v interface{}) string {
+	if f, ok := v.(float64); ok && f == float64(int64(f)) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return fmt.Sprint(v)
+}
+
+func compare(have, want, lookup string) bool {
+	switch lookup {
+	case "":
+		return have == want
+	case "ie":
+		return strings.EqualFold(have, want)
+	case "ic":
+		return strings.Contains(strings.ToLower(have), strings.ToLower(want))
+	case "isw":
+		return strings.HasPrefix(strings.ToLower(have), strings.ToLower(want))
+	case "iew":
+		return strings.HasSuffix(strings.ToLower(have), strings.ToLower(want))
+	case "gt", "gte", "lt", "lte":
+		h, err1 := strconv.ParseFloat(have, 64)
+		w, err2 := strconv.ParseFloat(want, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		switch lookup {
+		case "gt":
+			return h > w
+		case "gte":
+			return h >= w
+		case "lt":
+			return h < w
+		default:
+			return h <= w
+		}
+	}
+	return false
+}
+
+// matchesNetwork handles the IPAM filters that compare networks rather than
+// values. handled is false if field is not one of them.
+func matchesNetwork(obj map[string]interface{}, field string, values []string) (ok, handled bool) {
+	switch field {
+	case "within", "within_include", "parent", "contains", "mask_length":
+	case "address":
+		// IP addresses match on the host, whatever the prefix length.
+		if _, ok := obj["address"].(string); !ok {
+			return false, false
+		}
+	default:
+		return false, false
+	}
+
+	if start, end, ok := rangeBounds(obj); ok {
+		// IP ranges match the parents holding both of their bounds.
+		if field != "parent" {
+			return false, true
+		}
+		for _, v := range values {
+			if q, err := parseNetwork(v); err == nil && q.Masked().Contains(start.Addr()) && q.Masked().Contains(end.Addr()) {
+				return true, true
+			}
+		}
+		return false, true
+	}
+
+	var own netip.Prefix
+	if s, ok := obj["prefix"].(string); ok {
+		own, _ = netip.ParsePrefix(s)
+	} else if s, ok := obj["address"].(string); ok {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			own = p
+			if field != "mask_length" {
+				own = netip.PrefixFrom(p.Addr(), p.Addr().BitLen())
+			}
+		}
+	}
+	if !own.IsValid() {
+		return false, true
+	}
+
+	for _, v := range values {
+		if field == "mask_length" {
+			if v == strconv.Itoa(own.Bits()) {
+				return true, true
+			}
+			continue
+		}
+		q, err := parseNetwork(v)
+		if err != nil {
+			continue
+		}
+		if field == "address" {
+			if q.Addr() == own.Addr() {
+				return true, true
+			}
+			continue
+		}
+		q = q.Masked()
+		switch field {
+		case "within":
+			if q.Bits() < own.Bits() && q.Contains(own.Addr()) {
+				return true, true
+			}
+		case "within_include", "parent":
+			if q.Bits() <= own.Bits() && q.Contains(own.Addr()) {
+				return true, true
+			}
+		case "contains":
+			if own.Bits() <= q.Bits() && own.Masked().Contains(q.Addr()) {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+func parseNetwork(v string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(v); err == nil {
+		return p, nil
+	}
+	a, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+func search(v interface{}, needle string) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.Contains(strings.ToLower(v), needle)
+	case map[string]interface{}:
+		for k, item := range v {
+			if k == "url" {
+				continue
+			}
+			if search(item, needle) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if search(item, needle) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakenetbox
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/fbreckle/go-netbox/netbox/models"
+)
+
+// readModels maps every endpoint the fake knows about to the go-netbox model
+// returned by its read serializer. Endpoints that are not listed here are
+// still served, but objects are echoed back exactly as they were written.
+var readModels = map[string]interface{}{
+	"circuits/circuit-terminations":   models.CircuitTermination{},
+	"circuits/circuit-types":          models.CircuitType{},
+	"circuits/circuits":               models.Circuit{},
+	"circuits/providers":              models.Provider{},
+	"dcim/cables":                     models.Cable{},
+	"dcim/console-ports":              models.ConsolePort{},
+	"dcim/console-server-ports":       models.ConsoleServerPort{},
+	"dcim/device-roles":               models.DeviceRole{},
+	"dcim/device-types":               models.DeviceType{},
+	"dcim/devices":                    models.DeviceWithConfigContext{},
+	"dcim/front-ports":                models.FrontPort{},
+	"dcim/interfaces":                 models.Interface{},
+	"dcim/inventory-item-roles":       models.InventoryItemRole{},
+	"dcim/inventory-items":            models.InventoryItem{},
+	"dcim/locations":                  models.Location{},
+	"dcim/manufacturers":              models.Manufacturer{},
+	"dcim/module-bays":                models.ModuleBay{},
+	"dcim/module-types":               models.ModuleType{},
+	"dcim/modules":                    models.Module{},
+	"dcim/platforms":                  models.Platform{},
+	"dcim/power-feeds":                models.PowerFeed{},
+	"dcim/power-outlets":              models.PowerOutlet{},
+	"dcim/power-panels":               models.PowerPanel{},
+	"dcim/power-ports":                models.PowerPort{},
+	"dcim/rack-reservations":          models.RackReservation{},
+	"dcim/rack-roles":                 models.RackRole{},
+	"dcim/racks":                      models.Rack{},
+	"dcim/rear-ports":                 models.RearPort{},
+	"dcim/regions":                    models.Region{},
+	"dcim/site-groups":                models.SiteGroup{},
+	"dcim/sites":                      models.Site{},
+	"dcim/virtual-chassis":            models.VirtualChassis{},
+	"extras/custom-field-choice-sets": models.CustomFieldChoiceSet{},
+	"extras/custom-fields":            models.CustomField{},
+	"extras/event-rules":              models.EventRule{},
+	"extras/journal-entries":          models.JournalEntry{},
+	"extras/tags":                     models.Tag{},
+	"extras/webhooks":                 models.Webhook{},
+	"ipam/aggregates":                 models.Aggregate{},
+	"ipam/asns":                       models.ASN{},
+	"ipam/ip-addresses":               models.IPAddress{},
+	"ipam/ip-ranges":                  models.IPRange{},
+	"ipam/prefixes":                   models.Prefix{},
+	"ipam/rirs":                       models.RIR{},
+	"ipam/roles":                      models.Role{},
+	"ipam/route-targets":              models.RouteTarget{},
+	"ipam/services":                   models.Service{},
+	"ipam/vlan-groups":                models.VLANGroup{},
+	"ipam/vlans":                      models.VLAN{},
+	"ipam/vrfs":                       models.VRF{},
+	"tenancy/contact-assignments":     models.ContactAssignment{},
+	"tenancy/contact-groups":          models.ContactGroup{},
+	"tenancy/contact-roles":           models.ContactRole{},
+	"tenancy/contacts":                models.Contact{},
+	"tenancy/tenant-groups":           models.TenantGroup{},
+	"tenancy/tenants":                 models.Tenant{},
+	"users/groups":                    models.Group{},
+	"users/permissions":               models.ObjectPermission{},
+	"users/tokens":                    models.Token{},
+	"users/users":                     models.User{},
+	"virtualization/cluster-groups":   models.ClusterGroup{},
+	"virtualization/cluster-types":    models.ClusterType{},
+	"virtualization/clusters":         models.Cluster{},
+	"virtualization/interfaces":       models.VMInterface{},
+	"virtualization/virtual-disks":    models.VirtualDisk{},
+	"virtualization/virtual-machines": models.VirtualMachineWithConfigContext{},
+	"vpn/tunnel-groups":               models.TunnelGroup{},
+	"vpn/tunnel-terminations":         models.TunnelTermination{},
+	"vpn/tunnels":                     models.Tunnel{},
+}
+
+// modelTypes is readModels keyed by endpoint with reflected types, and
+// nestedEndpoints maps the name of each Nested* model to the endpoint holding
+// the objects it refers to.
+var (
+	modelTypes      = map[string]reflect.Type{}
+	nestedEndpoints = map[string]string{}
+)
+
+func init() {
+	for endpoint, model := range readModels {
+		t := reflect.TypeOf(model)
+		modelTypes[endpoint] = t
+		nestedEndpoints["Nested"+strings.TrimSuffix(t.Name(), "WithConfigContext")] = endpoint
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakenetbox
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// render turns a stored object, which is kept in the shape it was written in,
+// into the shape of the NetBox read serializer: foreign keys become nested
+// objects, choice values become value/label pairs and server-computed fields
+// are filled in.
+func (s *Server) render(endpoint string, obj map[string]interface{}) map[string]interface{} {
+	out := copyValue(obj).(map[string]interface{})
+	id, _ := toInt(obj["id"])
+	out["url"] = fmt.Sprintf("%s/api/%s/%d/", s.URL, endpoint, id)
+	out["display"] = display(obj)
+	computeFields(endpoint, out)
+
+	t, ok := modelTypes[endpoint]
+	if !ok {
+		return out
+	}
+	return s.shape(out, t, 0).(map[string]interface{})
+}
+
+func (s *Server) shape(v interface{}, t reflect.Type, depth int) interface{} {
+	if v == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(t)
+		if m, ok := v.(map[string]interface{}); ok {
+			for name, ft := range fields {
+				if fv, ok := m[name]; ok {
+					m[name] = s.shape(fv, ft, depth)
+				}
+			}
+			return m
+		}
+		if _, ok := fields["id"]; ok {
+			return s.nested(v, t, fields, depth)
+		}
+		_, hasValue := fields["value"]
+		_, hasLabel := fields["label"]
+		if hasValue && hasLabel {
+			return map[string]interface{}{"value": v, "label": label(v)}
+		}
+	case reflect.Slice:
+		if items, ok := v.([]interface{}); ok {
+			res := make([]interface{}, len(items))
+			for i, item := range items {
+				res[i] = s.shape(item, t.Elem(), depth)
+			}
+			return res
+		}
+	}
+	return v
+}
+
+// nested renders a foreign key as the Nested* model t, copying the brief
+// fields from the referenced object when the fake holds it.
+func (s *Server) nested(v interface{}, t reflect.Type, fields map[string]reflect.Type, depth int) interface{} {
+	id, ok := toInt(v)
+	if !ok {
+		return v
+	}
+	res := map[string]interface{}{"id": id, "display": fmt.Sprint(id)}
+
+	endpoint, ok := nestedEndpoints[t.Name()]
+	if !ok || depth > 1 {
+		return res
+	}
+	ref, ok := s.objects[endpoint][id]
+	if !ok {
+		return res
+	}
+	res["url"] = fmt.Sprintf("%s/api/%s/%d/", s.URL, endpoint, id)
+	res["display"] = display(ref)
+	for name, ft := range fields {
+		switch name {
+		case "id", "url", "display":
+			continue
+		}
+		if fv, ok := ref[name]; ok {
+			res[name] = s.shape(copyValue(fv), ft, depth+1)
+		}
+	}
+	return res
+}
+
+var jsonFieldsCache sync.Map
+
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if fields, ok := jsonFieldsCache.Load(t); ok {
+		return fields.(map[string]reflect.Type)
+	}
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.Type
+	}
+	jsonFieldsCache.Store(t, fields)
+	return fields
+}
+
+// computeFields fills in the fields NetBox derives from the written ones.
+func computeFields(endpoint string, obj map[string]interface{}) {
+	var addr string
+	switch endpoint {
+	case "ipam/prefixes", "ipam/aggregates":
+		addr, _ = obj["prefix"].(string)
+	case "ipam/ip-addresses":
+		addr, _ = obj["address"].(string)
+	case "ipam/ip-ranges":
+		addr, _ = obj["start_address"].(string)
+		if start, end, ok := rangeBounds(obj); ok {
+			obj["size"] = rangeSize(start, end)
+		}
+	}
+	if p, err := netip.ParsePrefix(addr); err == nil {
+		if p.Addr().Is4() {
+			obj["family"] = 4
+		} else {
+			obj["family"] = 6
+		}
+	}
+}
+
+func display(obj map[string]interface{}) string {
+	for _, key := range []string{"name", "model", "address", "prefix", "cid", "username", "vid", "asn"} {
+		if v, ok := obj[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	id, _ := toInt(obj["id"])
+	return fmt.Sprint(id)
+}
+
+func label(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		words := strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(v))
+		for i, w := range words {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+		return strings.Join(words, " ")
+	case float64, int, int64:
+		if n, _ := toInt(v); n == 4 || n == 6 {
+			return fmt.Sprintf("IPv%d", n)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = copyValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = copyValue(item)
+		}
+		return res
+	}
+	return v
+}
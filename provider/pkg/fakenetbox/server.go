@@ -0,0 +1,428 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakenetbox provides an in-memory stand-in for the NetBox REST API.
+//
+// It is meant for tests that need to drive the provider end to end without a
+// real NetBox instance. Objects are kept in memory per endpoint, write payloads
+// are echoed back in the shape of the NetBox read serializers, and list
+// endpoints support the common query filters and limit/offset pagination.
+package fakenetbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultVersion is the NetBox version reported by /api/status/.
+const DefaultVersion = "3.7.4"
+
+// Server is an in-memory fake of the NetBox REST API.
+type Server struct {
+	*httptest.Server
+
+	// Token, when set, is the only API token accepted by the server.
+	Token string
+	// Version is the NetBox version reported by /api/status/.
+	Version string
+
+	mu      sync.Mutex
+	nextID  int64
+	objects map[string]map[int64]map[string]interface{}
+	hooks   []Hook
+}
+
+// Hook can intercept a request before the fake handles it. It returns true if
+// it wrote a response itself.
+type Hook func(w http.ResponseWriter, r *http.Request) bool
+
+// NewServer starts a new fake NetBox server. Callers must Close it when done.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a fake NetBox server that is not listening yet.
+func NewUnstartedServer() *Server {
+	s := &Server{
+		Version: DefaultVersion,
+		objects: map[string]map[int64]map[string]interface{}{},
+	}
+	s.Server = httptest.NewUnstartedServer(s)
+	return s
+}
+
+// AddHook registers a hook that runs before every request.
+func (s *Server) AddHook(h Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, h)
+}
+
+// Create stores obj under the given endpoint (for example "dcim/sites") and
+// returns its ID. It is useful to seed fixtures.
+func (s *Server) Create(endpoint string, obj map[string]interface{}) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(normalizeEndpoint(endpoint), obj)
+}
+
+// Get returns the rendered object stored under endpoint with the given ID.
+func (s *Server) Get(endpoint string, id int64) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint = normalizeEndpoint(endpoint)
+	obj, ok := s.objects[endpoint][id]
+	if !ok {
+		return nil, false
+	}
+	return s.render(endpoint, obj), true
+}
+
+// List returns all rendered objects stored under endpoint, ordered by ID.
+func (s *Server) List(endpoint string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint = normalizeEndpoint(endpoint)
+	var res []map[string]interface{}
+	for _, id := range s.ids(endpoint) {
+		res = append(res, s.render(endpoint, s.objects[endpoint][id]))
+	}
+	return res
+}
+
+// Delete removes the object stored under endpoint with the given ID.
+func (s *Server) Delete(endpoint string, id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint = normalizeEndpoint(endpoint)
+	if _, ok := s.objects[endpoint][id]; !ok {
+		return false
+	}
+	delete(s.objects[endpoint], id)
+	return true
+}
+
+// Reset drops every stored object.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects = map[string]map[int64]map[string]interface{}{}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	hooks := append([]Hook(nil), s.hooks...)
+	s.mu.Unlock()
+	for _, h := range hooks {
+		if h(w, r) {
+			return
+		}
+	}
+
+	if s.Token != "" && r.Header.Get("Authorization") != "Token "+s.Token {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"detail": "Invalid token",
+		})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/")
+	if path == r.URL.Path {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+	path = strings.Trim(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path == "status" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"django-version":     "4.2.10",
+			"installed-apps":     map[string]interface{}{},
+			"netbox-version":     s.Version,
+			"plugins":            map[string]interface{}{},
+			"python-version":     "3.11.6",
+			"rq-workers-running": 1,
+		})
+		return
+	}
+
+	segments := strings.Split(path, "/")
+	if n := len(segments); n >= 2 {
+		if handler, ok := actions[segments[n-1]]; ok && n >= 3 {
+			if id, err := strconv.ParseInt(segments[n-2], 10, 64); err == nil {
+				handler(s, w, r, strings.Join(segments[:n-2], "/"), id)
+				return
+			}
+		}
+		if id, err := strconv.ParseInt(segments[n-1], 10, 64); err == nil {
+			s.serveObject(w, r, strings.Join(segments[:n-1], "/"), id)
+			return
+		}
+	}
+	s.serveCollection(w, r, path)
+}
+
+func (s *Server) serveCollection(w http.ResponseWriter, r *http.Request, endpoint string) {
+	switch r.Method {
+	case http.MethodGet:
+		var objects []map[string]interface{}
+		for _, id := range s.ids(endpoint) {
+			obj := s.objects[endpoint][id]
+			if matchesQuery(s.render(endpoint, obj), r.URL.Query()) {
+				objects = append(objects, obj)
+			}
+		}
+		writePage(w, r, s.renderAll(endpoint, objects))
+	case http.MethodPost:
+		bodies, many, err := decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var created []map[string]interface{}
+		for _, body := range bodies {
+			id := s.create(endpoint, body)
+			created = append(created, s.objects[endpoint][id])
+		}
+		writeObjects(w, http.StatusCreated, s.renderAll(endpoint, created), many)
+	case http.MethodPut, http.MethodPatch:
+		bodies, many, err := decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var updated []map[string]interface{}
+		for _, body := range bodies {
+			id, _ := toInt(body["id"])
+			obj, ok := s.objects[endpoint][id]
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+				return
+			}
+			s.update(obj, body, r.Method == http.MethodPut)
+			updated = append(updated, obj)
+		}
+		writeObjects(w, http.StatusOK, s.renderAll(endpoint, updated), many)
+	case http.MethodDelete:
+		bodies, _, err := decodeBodies(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		for _, body := range bodies {
+			id, _ := toInt(body["id"])
+			delete(s.objects[endpoint], id)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, endpoint string, id int64) {
+	obj, ok := s.objects[endpoint][id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.render(endpoint, obj))
+	case http.MethodPut, http.MethodPatch:
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.update(obj, body, r.Method == http.MethodPut)
+		writeJSON(w, http.StatusOK, s.render(endpoint, obj))
+	case http.MethodDelete:
+		delete(s.objects[endpoint], id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) create(endpoint string, body map[string]interface{}) int64 {
+	s.nextID++
+	id := s.nextID
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	obj := map[string]interface{}{}
+	for k, v := range body {
+		obj[k] = v
+	}
+	obj["id"] = id
+	obj["created"] = now
+	obj["last_updated"] = now
+
+	if s.objects[endpoint] == nil {
+		s.objects[endpoint] = map[int64]map[string]interface{}{}
+	}
+	s.objects[endpoint][id] = obj
+	return id
+}
+
+func (s *Server) update(obj, body map[string]interface{}, replace bool) {
+	if replace {
+		for k := range obj {
+			switch k {
+			case "id", "created":
+			default:
+				delete(obj, k)
+			}
+		}
+	}
+	for k, v := range body {
+		if k == "id" {
+			continue
+		}
+		obj[k] = v
+	}
+	obj["last_updated"] = time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func (s *Server) ids(endpoint string) []int64 {
+	ids := make([]int64, 0, len(s.objects[endpoint]))
+	for id := range s.objects[endpoint] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (s *Server) renderAll(endpoint string, objects []map[string]interface{}) []map[string]interface{} {
+	res := make([]map[string]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		res = append(res, s.render(endpoint, obj))
+	}
+	return res
+}
+
+func normalizeEndpoint(endpoint string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimPrefix(endpoint, "/"), "api/"), "/")
+}
+
+func decodeBodies(r *http.Request) ([]map[string]interface{}, bool, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, false, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+		var bodies []map[string]interface{}
+		err := json.Unmarshal(raw, &bodies)
+		return bodies, true, err
+	}
+	var body map[string]interface{}
+	err := json.Unmarshal(raw, &body)
+	return []map[string]interface{}{body}, false, err
+}
+
+func writePage(w http.ResponseWriter, r *http.Request, results []map[string]interface{}) {
+	query := r.URL.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	count := len(results)
+	if offset > count {
+		offset = count
+	}
+	end := offset + limit
+	if end > count {
+		end = count
+	}
+
+	page := map[string]interface{}{
+		"count":    count,
+		"next":     nil,
+		"previous": nil,
+		"results":  results[offset:end],
+	}
+	if end < count {
+		page["next"] = pageURL(r, end, limit)
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		page["previous"] = pageURL(r, prev, limit)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func pageURL(r *http.Request, offset, limit int) string {
+	u := *r.URL
+	query := u.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+	u.Scheme = "http"
+	u.Host = r.Host
+	return u.String()
+}
+
+func writeObjects(w http.ResponseWriter, status int, objects []map[string]interface{}, many bool) {
+	if many {
+		writeJSON(w, status, objects)
+		return
+	}
+	writeJSON(w, status, objects[0])
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{"detail": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(fmt.Sprintf("fakenetbox: cannot encode response: %v", err))
+	}
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
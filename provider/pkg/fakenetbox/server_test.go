@@ -0,0 +1,252 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakenetbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func do(t *testing.T, s *Server, method, path string, body interface{}) (int, interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Token secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var out interface{}
+	if res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode, out
+}
+
+func TestCRUD(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Token = "secret"
+
+	code, out := do(t, s, http.MethodPost, "/api/dcim/sites/", map[string]interface{}{
+		"name": "par1", "slug": "par1", "status": "active",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("create returned %d: %v", code, out)
+	}
+	site := out.(map[string]interface{})
+	if got := site["status"].(map[string]interface{})["value"]; got != "active" {
+		t.Errorf("status = %v, want a value/label pair for active", site["status"])
+	}
+
+	code, out = do(t, s, http.MethodPost, "/api/dcim/racks/", map[string]interface{}{
+		"name": "r1", "site": site["id"], "status": "active",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("create returned %d: %v", code, out)
+	}
+	rack := out.(map[string]interface{})
+	if got := rack["site"].(map[string]interface{})["slug"]; got != "par1" {
+		t.Errorf("rack site = %v, want a nested site", rack["site"])
+	}
+
+	code, out = do(t, s, http.MethodPatch, "/api/dcim/sites/1/", map[string]interface{}{"description": "paris"})
+	if code != http.StatusOK || out.(map[string]interface{})["description"] != "paris" {
+		t.Errorf("patch returned %d: %v", code, out)
+	}
+
+	if code, _ = do(t, s, http.MethodDelete, "/api/dcim/sites/1/", nil); code != http.StatusNoContent {
+		t.Errorf("delete returned %d", code)
+	}
+	if code, _ = do(t, s, http.MethodGet, "/api/dcim/sites/1/", nil); code != http.StatusNotFound {
+		t.Errorf("read after delete returned %d", code)
+	}
+
+	s.Token = "other"
+	if code, _ = do(t, s, http.MethodGet, "/api/dcim/racks/", nil); code != http.StatusForbidden {
+		t.Errorf("list with a wrong token returned %d", code)
+	}
+}
+
+func TestFilterAndPagination(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	for _, name := range []string{"alpha", "beta", "gamma", "delta"} {
+		s.Create("dcim/sites", map[string]interface{}{"name": name, "slug": name, "status": "active"})
+	}
+	s.Create("dcim/sites", map[string]interface{}{"name": "omega", "slug": "omega", "status": "planned"})
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"", 5},
+		{"?name=beta", 1},
+		{"?name=beta&name=gamma", 2},
+		{"?name__ic=TA", 2},
+		{"?name__n=alpha", 4},
+		{"?status=planned", 1},
+		{"?id__gte=3", 3},
+		{"?q=MEG", 1},
+	}
+	for _, tt := range tests {
+		_, out := do(t, s, http.MethodGet, "/api/dcim/sites/"+tt.query, nil)
+		page := out.(map[string]interface{})
+		if got := int(page["count"].(float64)); got != tt.count {
+			t.Errorf("%q: count = %d, want %d", tt.query, got, tt.count)
+		}
+	}
+
+	s.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.0.0.1/24", "status": "active"})
+	for query, count := range map[string]int{"10.0.0.1": 1, "10.0.0.1/32": 1, "10.0.0.1/24": 1, "10.0.0.2/24": 0} {
+		_, out := do(t, s, http.MethodGet, "/api/ipam/ip-addresses/?address="+query, nil)
+		if got := int(out.(map[string]interface{})["count"].(float64)); got != count {
+			t.Errorf("address=%s: count = %d, want %d", query, got, count)
+		}
+	}
+
+	_, out := do(t, s, http.MethodGet, "/api/dcim/sites/?limit=2&offset=2", nil)
+	page := out.(map[string]interface{})
+	if len(page["results"].([]interface{})) != 2 || page["next"] == nil || page["previous"] == nil {
+		t.Errorf("unexpected page: %v", page)
+	}
+}
+
+func TestAvailableIPsAndPrefixes(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/29", "status": "active"})
+	s.Create("ipam/ip-addresses", map[string]interface{}{"address": "10.0.0.1/29", "status": "active"})
+
+	_, out := do(t, s, http.MethodGet, "/api/ipam/prefixes/1/available-ips/", nil)
+	if got := len(out.([]interface{})); got != 5 {
+		t.Errorf("available IPs = %d, want 5", got)
+	}
+
+	code, out := do(t, s, http.MethodPost, "/api/ipam/prefixes/1/available-ips/", []interface{}{
+		map[string]interface{}{"status": "active"},
+		map[string]interface{}{"status": "reserved"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("allocation returned %d: %v", code, out)
+	}
+	ips := out.([]interface{})
+	if a := ips[0].(map[string]interface{})["address"]; a != "10.0.0.2/29" {
+		t.Errorf("first allocated address = %v, want 10.0.0.2/29", a)
+	}
+
+	code, _ = do(t, s, http.MethodPost, "/api/ipam/prefixes/1/available-ips/", []interface{}{
+		map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{},
+	})
+	if code != http.StatusConflict {
+		t.Errorf("over-allocation returned %d, want 409", code)
+	}
+
+	s.Create("ipam/prefixes", map[string]interface{}{"prefix": "192.168.0.0/24", "status": "container"})
+	s.Create("ipam/prefixes", map[string]interface{}{"prefix": "192.168.0.0/26", "status": "active"})
+	code, out = do(t, s, http.MethodPost, "/api/ipam/prefixes/5/available-prefixes/", map[string]interface{}{
+		"prefix_length": 25, "status": "active",
+	})
+	if code != http.StatusCreated || out.(map[string]interface{})["prefix"] != "192.168.0.128/25" {
+		t.Errorf("prefix allocation returned %d: %v", code, out)
+	}
+	_, out = do(t, s, http.MethodGet, "/api/ipam/prefixes/5/available-prefixes/", nil)
+	if free := out.([]interface{}); len(free) != 1 || free[0].(map[string]interface{})["prefix"] != "192.168.0.64/26" {
+		t.Errorf("available prefixes = %v, want [192.168.0.64/26]", free)
+	}
+}
+
+func TestAvailableVLANs(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	group := s.Create("ipam/vlan-groups", map[string]interface{}{"name": "dc1", "slug": "dc1", "min_vid": 100, "max_vid": 102})
+	s.Create("ipam/vlans", map[string]interface{}{"name": "first", "vid": 100, "group": group, "status": "active"})
+	path := fmt.Sprintf("/api/ipam/vlan-groups/%d/available-vlans/", group)
+
+	_, out := do(t, s, http.MethodGet, path, nil)
+	if got := len(out.([]interface{})); got != 2 {
+		t.Errorf("available VLANs = %d, want 2", got)
+	}
+	code, out := do(t, s, http.MethodPost, path, []interface{}{map[string]interface{}{"name": "users", "status": "active"}})
+	if code != http.StatusCreated {
+		t.Fatalf("allocation returned %d: %v", code, out)
+	}
+	if vid := out.([]interface{})[0].(map[string]interface{})["vid"]; vid != 101.0 {
+		t.Errorf("allocated VID = %v, want 101", vid)
+	}
+	code, _ = do(t, s, http.MethodPost, path, []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}})
+	if code != http.StatusConflict {
+		t.Errorf("over-allocation returned %d, want 409", code)
+	}
+}
+
+func TestAvailableASNs(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	rir := s.Create("ipam/rirs", map[string]interface{}{"name": "private", "slug": "private", "is_private": true})
+	other := s.Create("ipam/rirs", map[string]interface{}{"name": "other", "slug": "other"})
+	asnRange := s.Create("ipam/asn-ranges", map[string]interface{}{"name": "dc", "slug": "dc", "rir": rir, "start": 65000, "end": 65002})
+	s.Create("ipam/asns", map[string]interface{}{"asn": 65000, "rir": rir})
+	s.Create("ipam/asns", map[string]interface{}{"asn": 65001, "rir": other})
+	path := fmt.Sprintf("/api/ipam/asn-ranges/%d/available-asns/", asnRange)
+
+	_, out := do(t, s, http.MethodGet, path, nil)
+	if got := len(out.([]interface{})); got != 2 {
+		t.Errorf("available ASNs = %d, want 2", got)
+	}
+	code, out := do(t, s, http.MethodPost, path, []interface{}{map[string]interface{}{"description": "leaf"}})
+	if code != http.StatusCreated {
+		t.Fatalf("allocation returned %d: %v", code, out)
+	}
+	asn := out.([]interface{})[0].(map[string]interface{})
+	if asn["asn"] != 65001.0 {
+		t.Errorf("allocated ASN = %v, want 65001", asn["asn"])
+	}
+	if id := asn["rir"].(map[string]interface{})["id"]; id != float64(rir) {
+		t.Errorf("allocated RIR = %v, want %d", id, rir)
+	}
+	code, _ = do(t, s, http.MethodPost, path, []interface{}{map[string]interface{}{}, map[string]interface{}{}})
+	if code != http.StatusConflict {
+		t.Errorf("over-allocation returned %d, want 409", code)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	_, out := do(t, s, http.MethodGet, "/api/status/", nil)
+	if v := out.(map[string]interface{})["netbox-version"]; v != DefaultVersion {
+		t.Errorf("netbox-version = %v, want %s", v, DefaultVersion)
+	}
+}
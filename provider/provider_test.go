@@ -0,0 +1,597 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+// testProvider drives a bridged provider server configured against a fake
+// NetBox.
+type testProvider struct {
+	t      *testing.T
+	netbox *fakenetbox.Server
+	server pulumirpc.ResourceProviderServer
+	info   tfbridge.ProviderInfo
+}
+
+func newTestProvider(t *testing.T, netbox *fakenetbox.Server, config resource.PropertyMap) *testProvider {
+	t.Helper()
+
+	info := Provider()
+	p := &testProvider{
+		t:      t,
+		netbox: netbox,
+		server: newServer(tfbridge.NewProvider(context.Background(), nil, "netbox", "", info.P, info, nil)),
+		info:   info,
+	}
+
+	args := resource.PropertyMap{
+		"serverUrl": resource.NewStringProperty(netbox.URL),
+		"apiToken":  resource.NewStringProperty("0123456789abcdef0123456789abcdef01234567"),
+	}
+	for k, v := range config {
+		args[k] = v
+	}
+	_, err := p.server.Configure(context.Background(), &pulumirpc.ConfigureRequest{
+		Args: p.marshal(args),
+	})
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	return p
+}
+
+func (p *testProvider) urn(tfName, name string) resource.URN {
+	tok := p.info.Resources[tfName].Tok
+	return resource.NewURN("test", "netbox", "", tokens.Type(tok), name)
+}
+
+func (p *testProvider) marshal(props resource.PropertyMap) *structpb.Struct {
+	p.t.Helper()
+	s, err := plugin.MarshalProperties(props, plugin.MarshalOptions{KeepUnknowns: true, KeepSecrets: true})
+	if err != nil {
+		p.t.Fatalf("marshal: %v", err)
+	}
+	return s
+}
+
+func (p *testProvider) unmarshal(s *structpb.Struct) resource.PropertyMap {
+	p.t.Helper()
+	props, err := plugin.UnmarshalProperties(s, plugin.MarshalOptions{KeepUnknowns: true, KeepSecrets: true})
+	if err != nil {
+		p.t.Fatalf("unmarshal: %v", err)
+	}
+	return props
+}
+
+func (p *testProvider) check(urn resource.URN, olds, news resource.PropertyMap) resource.PropertyMap {
+	p.t.Helper()
+	resp, err := p.server.Check(context.Background(), &pulumirpc.CheckRequest{
+		Urn:  string(urn),
+		Olds: p.marshal(olds),
+		News: p.marshal(news),
+	})
+	if err != nil {
+		p.t.Fatalf("check: %v", err)
+	}
+	for _, f := range resp.GetFailures() {
+		p.t.Errorf("check failure on %q: %s", f.GetProperty(), f.GetReason())
+	}
+	if p.t.Failed() {
+		p.t.FailNow()
+	}
+	return p.unmarshal(resp.GetInputs())
+}
+
+func (p *testProvider) create(urn resource.URN, inputs resource.PropertyMap) (string, resource.PropertyMap) {
+	p.t.Helper()
+	id, props, err := p.tryCreate(urn, inputs)
+	if err != nil {
+		p.t.Fatalf("create: %v", err)
+	}
+	return id, props
+}
+
+// tryCreate is create for callers expecting it to fail.
+func (p *testProvider) tryCreate(urn resource.URN, inputs resource.PropertyMap) (string, resource.PropertyMap, error) {
+	p.t.Helper()
+	resp, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn:        string(urn),
+		Properties: p.marshal(inputs),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.GetId(), p.unmarshal(resp.GetProperties()), nil
+}
+
+// invoke calls the function of the data source tfName, and returns its
+// result or the reason it failed.
+func (p *testProvider) invoke(tfName string, args map[string]interface{}) (resource.PropertyMap, error) {
+	p.t.Helper()
+	resp, err := p.server.Invoke(context.Background(), &pulumirpc.InvokeRequest{
+		Tok:  string(p.info.DataSources[tfName].Tok),
+		Args: p.marshal(resource.NewPropertyMapFromMap(args)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failures := resp.GetFailures(); len(failures) > 0 {
+		return nil, fmt.Errorf("%s: %s", failures[0].GetProperty(), failures[0].GetReason())
+	}
+	return p.unmarshal(resp.GetReturn()), nil
+}
+
+func (p *testProvider) read(urn resource.URN, id string, state, inputs resource.PropertyMap) (string, resource.PropertyMap, resource.PropertyMap) {
+	p.t.Helper()
+	req := &pulumirpc.ReadRequest{Id: id, Urn: string(urn)}
+	if state != nil {
+		req.Properties = p.marshal(state)
+		req.Inputs = p.marshal(inputs)
+	}
+	resp, err := p.server.Read(context.Background(), req)
+	if err != nil {
+		p.t.Fatalf("read: %v", err)
+	}
+	return resp.GetId(), p.unmarshal(resp.GetProperties()), p.unmarshal(resp.GetInputs())
+}
+
+func (p *testProvider) update(urn resource.URN, id string, olds, news resource.PropertyMap) resource.PropertyMap {
+	p.t.Helper()
+	props, err := p.tryUpdate(urn, id, olds, news)
+	if err != nil {
+		p.t.Fatalf("update: %v", err)
+	}
+	return props
+}
+
+// tryUpdate is update for callers expecting it to fail.
+func (p *testProvider) tryUpdate(urn resource.URN, id string, olds, news resource.PropertyMap) (resource.PropertyMap, error) {
+	p.t.Helper()
+	resp, err := p.server.Update(context.Background(), &pulumirpc.UpdateRequest{
+		Id:   id,
+		Urn:  string(urn),
+		Olds: p.marshal(olds),
+		News: p.marshal(news),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.unmarshal(resp.GetProperties()), nil
+}
+
+func (p *testProvider) delete(urn resource.URN, id string, state resource.PropertyMap) {
+	p.t.Helper()
+	if err := p.tryDelete(urn, id, state); err != nil {
+		p.t.Fatalf("delete: %v", err)
+	}
+}
+
+// tryDelete is delete for callers expecting it to fail.
+func (p *testProvider) tryDelete(urn resource.URN, id string, state resource.PropertyMap) error {
+	p.t.Helper()
+	_, err := p.server.Delete(context.Background(), &pulumirpc.DeleteRequest{
+		Id:         id,
+		Urn:        string(urn),
+		Properties: p.marshal(state),
+	})
+	return err
+}
+
+// fixtures seeds the objects the lifecycle cases refer to and returns their IDs
+// by name.
+func fixtures(s *fakenetbox.Server) map[string]int64 {
+	ids := map[string]int64{}
+	ids["site"] = s.Create("dcim/sites", map[string]interface{}{"name": "site", "slug": "site", "status": "active"})
+	ids["manufacturer"] = s.Create("dcim/manufacturers", map[string]interface{}{"name": "acme", "slug": "acme"})
+	ids["deviceType"] = s.Create("dcim/device-types", map[string]interface{}{
+		"model": "switch", "slug": "switch", "manufacturer": ids["manufacturer"], "u_height": 1,
+	})
+	ids["moduleType"] = s.Create("dcim/module-types", map[string]interface{}{"model": "linecard", "manufacturer": ids["manufacturer"]})
+	ids["deviceRole"] = s.Create("dcim/device-roles", map[string]interface{}{"name": "leaf", "slug": "leaf", "color": "ff0000"})
+	ids["rack"] = s.Create("dcim/racks", map[string]interface{}{"name": "r1", "site": ids["site"], "status": "active", "u_height": 42, "width": 19})
+	ids["device"] = s.Create("dcim/devices", map[string]interface{}{
+		"name": "leaf1", "site": ids["site"], "device_type": ids["deviceType"], "role": ids["deviceRole"], "status": "active",
+	})
+	ids["interface"] = s.Create("dcim/interfaces", map[string]interface{}{"name": "eth0", "device": ids["device"], "type": "1000base-t"})
+	ids["peerInterface"] = s.Create("dcim/interfaces", map[string]interface{}{"name": "eth1", "device": ids["device"], "type": "1000base-t"})
+	ids["rearPort"] = s.Create("dcim/rear-ports", map[string]interface{}{"name": "rp1", "device": ids["device"], "type": "8p8c", "positions": 1})
+	ids["moduleBay"] = s.Create("dcim/module-bays", map[string]interface{}{"name": "bay1", "device": ids["device"]})
+	ids["powerPanel"] = s.Create("dcim/power-panels", map[string]interface{}{"name": "pp1", "site": ids["site"]})
+	ids["clusterType"] = s.Create("virtualization/cluster-types", map[string]interface{}{"name": "kvm", "slug": "kvm"})
+	ids["vm"] = s.Create("virtualization/virtual-machines", map[string]interface{}{"name": "vm1", "status": "active"})
+	ids["prefix"] = s.Create("ipam/prefixes", map[string]interface{}{"prefix": "10.0.0.0/16", "status": "active"})
+	ids["ipAddress"] = s.Create("ipam/ip-addresses", map[string]interface{}{"address": "192.168.0.1/24", "status": "active"})
+	ids["rir"] = s.Create("ipam/rirs", map[string]interface{}{"name": "rfc1918", "slug": "rfc1918"})
+	ids["circuitProvider"] = s.Create("circuits/providers", map[string]interface{}{"name": "carrier", "slug": "carrier"})
+	ids["circuitType"] = s.Create("circuits/circuit-types", map[string]interface{}{"name": "transit", "slug": "transit"})
+	ids["circuit"] = s.Create("circuits/circuits", map[string]interface{}{
+		"cid": "c1", "provider": ids["circuitProvider"], "type": ids["circuitType"], "status": "active",
+	})
+	ids["contact"] = s.Create("tenancy/contacts", map[string]interface{}{"name": "noc"})
+	ids["contactRole"] = s.Create("tenancy/contact-roles", map[string]interface{}{"name": "owner", "slug": "owner"})
+	ids["user"] = s.Create("users/users", map[string]interface{}{"username": "automation"})
+	ids["webhook"] = s.Create("extras/webhooks", map[string]interface{}{"name": "hook", "payload_url": "https://example.com"})
+	ids["tunnelGroup"] = s.Create("vpn/tunnel-groups", map[string]interface{}{"name": "wan", "slug": "wan"})
+	ids["tunnel"] = s.Create("vpn/tunnels", map[string]interface{}{
+		"name": "t1", "status": "active", "encapsulation": "gre", "group": ids["tunnelGroup"],
+	})
+	ids["asnRange"] = s.Create("ipam/asn-ranges", map[string]interface{}{"name": "private", "slug": "private", "rir": ids["rir"], "start": 65000, "end": 65099})
+	ids["vlanGroup"] = s.Create("ipam/vlan-groups", map[string]interface{}{"name": "pool", "slug": "pool", "min_vid": 200, "max_vid": 299})
+	return ids
+}
+
+// lifecycleCase describes how to exercise one resource: the NetBox endpoint
+// holding its objects (empty if it does not own one), its inputs and the
+// inputs to change on update.
+type lifecycleCase struct {
+	endpoint string
+	inputs   map[string]interface{}
+	update   map[string]interface{}
+
+	// importID maps the resource ID to the ID expected by its importer.
+	importID func(id string) string
+	// imported are the inputs the import must recover, which are checked
+	// even if the read leaves them out.
+	imported []string
+	// skipUpdate and skipImport explain why a step cannot be exercised.
+	skipUpdate, skipImport string
+}
+
+func lifecycleCases(ids map[string]int64) map[string]lifecycleCase {
+	id := func(name string) interface{} { return float64(ids[name]) }
+	described := func(endpoint string, inputs map[string]interface{}) lifecycleCase {
+		return lifecycleCase{endpoint: endpoint, inputs: inputs, update: map[string]interface{}{"description": "updated"}}
+	}
+	changed := func(endpoint string, inputs, update map[string]interface{}) lifecycleCase {
+		return lifecycleCase{endpoint: endpoint, inputs: inputs, update: update}
+	}
+	named := func(endpoint string, inputs map[string]interface{}) lifecycleCase {
+		return lifecycleCase{endpoint: endpoint, inputs: inputs, update: map[string]interface{}{"name": inputs["name"].(string) + "-updated"}}
+	}
+
+	return map[string]lifecycleCase{
+		"netbox_aggregate":            described("ipam/aggregates", map[string]interface{}{"prefix": "1.1.1.0/25", "rirId": id("rir")}),
+		"netbox_asn":                  changed("ipam/asns", map[string]interface{}{"asn": 64512.0, "rirId": id("rir")}, map[string]interface{}{"asn": 64513.0}),
+		"netbox_available_ip_address": described("ipam/ip-addresses", map[string]interface{}{"prefixId": id("prefix")}),
+		"netbox_available_asn":        described("ipam/asns", map[string]interface{}{"asnRangeId": id("asnRange")}),
+		"netbox_available_vlan": {
+			endpoint: "ipam/vlans",
+			inputs:   map[string]interface{}{"name": "users", "groupId": id("vlanGroup")},
+			update:   map[string]interface{}{"description": "updated"},
+			imported: []string{"groupId"},
+		},
+		"netbox_available_prefix": {
+			endpoint: "ipam/prefixes",
+			inputs: map[string]interface{}{
+				"parentPrefixId": id("prefix"), "prefixLength": 24.0, "status": "active",
+			},
+			update: map[string]interface{}{"description": "updated"},
+			importID: func(id string) string {
+				return strconv.FormatInt(ids["prefix"], 10) + " " + id + " 24"
+			},
+		},
+		"netbox_cable": described("dcim/cables", map[string]interface{}{
+			"status": "connected",
+			"aTerminations": []interface{}{map[string]interface{}{
+				"objectType": "dcim.interface", "objectId": id("interface"),
+			}},
+			"bTerminations": []interface{}{map[string]interface{}{
+				"objectType": "dcim.interface", "objectId": id("peerInterface"),
+			}},
+		}),
+		"netbox_circuit": changed("circuits/circuits", map[string]interface{}{
+			"cid": "c2", "providerId": id("circuitProvider"), "typeId": id("circuitType"), "status": "active",
+		}, map[string]interface{}{"status": "offline"}),
+		"netbox_circuit_provider": named("circuits/providers", map[string]interface{}{"name": "isp"}),
+		"netbox_circuit_termination": changed("circuits/circuit-terminations", map[string]interface{}{
+			"circuitId": id("circuit"), "siteId": id("site"), "termSide": "A",
+		}, map[string]interface{}{"portSpeed": 1000.0}),
+		"netbox_circuit_type":  named("circuits/circuit-types", map[string]interface{}{"name": "mpls"}),
+		"netbox_cluster":       described("virtualization/clusters", map[string]interface{}{"name": "c1", "clusterTypeId": id("clusterType")}),
+		"netbox_cluster_group": described("virtualization/cluster-groups", map[string]interface{}{"name": "g1"}),
+		"netbox_cluster_type":  named("virtualization/cluster-types", map[string]interface{}{"name": "vmware"}),
+		"netbox_contact":       named("tenancy/contacts", map[string]interface{}{"name": "ops"}),
+		"netbox_contact_assignment": changed("tenancy/contact-assignments", map[string]interface{}{
+			"contactId": id("contact"), "roleId": id("contactRole"), "contentType": "dcim.device", "objectId": id("device"),
+		}, map[string]interface{}{"priority": "primary"}),
+		"netbox_contact_group": described("tenancy/contact-groups", map[string]interface{}{"name": "g1"}),
+		"netbox_contact_role":  named("tenancy/contact-roles", map[string]interface{}{"name": "admin"}),
+		"netbox_custom_field": described("extras/custom-fields", map[string]interface{}{
+			"name": "owner", "type": "text", "contentTypes": []interface{}{"dcim.site"}, "weight": 100.0,
+		}),
+		"netbox_custom_field_choice_set": described("extras/custom-field-choice-sets", map[string]interface{}{
+			"name": "colors", "extraChoices": []interface{}{[]interface{}{"red", "Red"}},
+		}),
+		"netbox_device": described("dcim/devices", map[string]interface{}{
+			"name": "leaf2", "deviceTypeId": id("deviceType"), "roleId": id("deviceRole"), "siteId": id("site"),
+		}),
+		"netbox_device_console_port":        described("dcim/console-ports", map[string]interface{}{"name": "con0", "deviceId": id("device")}),
+		"netbox_device_console_server_port": described("dcim/console-server-ports", map[string]interface{}{"name": "cs0", "deviceId": id("device")}),
+		"netbox_device_front_port": described("dcim/front-ports", map[string]interface{}{
+			"name": "fp1", "deviceId": id("device"), "rearPortId": id("rearPort"), "rearPortPosition": 1.0, "type": "8p8c",
+		}),
+		"netbox_device_interface": described("dcim/interfaces", map[string]interface{}{
+			"name": "eth2", "deviceId": id("device"), "type": "1000base-t",
+		}),
+		"netbox_device_module_bay":   described("dcim/module-bays", map[string]interface{}{"name": "bay2", "deviceId": id("device")}),
+		"netbox_device_power_outlet": described("dcim/power-outlets", map[string]interface{}{"name": "po1", "deviceId": id("device")}),
+		"netbox_device_power_port":   described("dcim/power-ports", map[string]interface{}{"name": "pp1", "deviceId": id("device")}),
+		"netbox_device_primary_ip": {
+			inputs: map[string]interface{}{
+				"deviceId": id("device"), "ipAddressId": id("ipAddress"),
+			},
+			update:     map[string]interface{}{"ipAddressVersion": 4.0},
+			skipImport: "the upstream importer does not set ip_address_version, so the read finds no primary IP",
+		},
+		"netbox_device_rear_port": described("dcim/rear-ports", map[string]interface{}{
+			"name": "rp2", "deviceId": id("device"), "positions": 2.0, "type": "8p8c",
+		}),
+		"netbox_device_role": described("dcim/device-roles", map[string]interface{}{"name": "spine", "colorHex": "00ff00"}),
+		"netbox_device_type": changed("dcim/device-types", map[string]interface{}{
+			"model": "router", "manufacturerId": id("manufacturer"),
+		}, map[string]interface{}{"partNumber": "R-1"}),
+		"netbox_event_rule": described("extras/event-rules", map[string]interface{}{
+			"name": "notify", "actionType": "webhook", "actionObjectId": id("webhook"),
+			"contentTypes": []interface{}{"dcim.device"}, "triggerOnCreate": true,
+		}),
+		"netbox_interface":      described("virtualization/interfaces", map[string]interface{}{"name": "eth0", "virtualMachineId": id("vm")}),
+		"netbox_inventory_item": described("dcim/inventory-items", map[string]interface{}{"name": "psu", "deviceId": id("device")}),
+		"netbox_inventory_item_role": described("dcim/inventory-item-roles", map[string]interface{}{
+			"name": "power", "slug": "power", "colorHex": "0000ff",
+		}),
+		"netbox_ip_address": described("ipam/ip-addresses", map[string]interface{}{"ipAddress": "10.1.0.1/24", "status": "active"}),
+		"netbox_ip_range": described("ipam/ip-ranges", map[string]interface{}{
+			"startAddress": "10.2.0.10/24", "endAddress": "10.2.0.20/24",
+		}),
+		"netbox_ipam_role":    described("ipam/roles", map[string]interface{}{"name": "mgmt"}),
+		"netbox_location":     described("dcim/locations", map[string]interface{}{"name": "room1", "siteId": id("site")}),
+		"netbox_manufacturer": named("dcim/manufacturers", map[string]interface{}{"name": "initech"}),
+		"netbox_module": described("dcim/modules", map[string]interface{}{
+			"deviceId": id("device"), "moduleBayId": id("moduleBay"), "moduleTypeId": id("moduleType"), "status": "active",
+		}),
+		"netbox_module_type": described("dcim/module-types", map[string]interface{}{"model": "psu", "manufacturerId": id("manufacturer")}),
+		"netbox_permission": described("users/permissions", map[string]interface{}{
+			"name": "read", "actions": []interface{}{"view"}, "objectTypes": []interface{}{"dcim.site"},
+		}),
+		"netbox_object": {
+			endpoint:   "plugins/netbox-dns/zones",
+			inputs:     map[string]interface{}{"path": "plugins/netbox-dns/zones", "body": `{"name":"example.com","status":"active"}`},
+			update:     map[string]interface{}{"body": `{"name":"example.com","status":"inactive"}`},
+			skipImport: "the keys of the body cannot be recovered on import",
+		},
+		"netbox_platform": named("dcim/platforms", map[string]interface{}{"name": "junos"}),
+		"netbox_power_feed": described("dcim/power-feeds", map[string]interface{}{
+			"name": "feed-a", "powerPanelId": id("powerPanel"), "status": "active", "type": "primary",
+			"supply": "ac", "phase": "single-phase", "voltage": 230.0, "amperage": 16.0, "maxPercentUtilization": 80.0,
+		}),
+		"netbox_power_panel": described("dcim/power-panels", map[string]interface{}{"name": "pp2", "siteId": id("site")}),
+		"netbox_prefix":      described("ipam/prefixes", map[string]interface{}{"prefix": "10.3.0.0/24", "status": "active"}),
+		"netbox_primary_ip": {
+			inputs: map[string]interface{}{
+				"virtualMachineId": id("vm"), "ipAddressId": id("ipAddress"),
+			},
+			update:     map[string]interface{}{"ipAddressVersion": 4.0},
+			skipImport: "the upstream importer does not set ip_address_version, so the read finds no primary IP",
+		},
+		"netbox_rack": described("dcim/racks", map[string]interface{}{
+			"name": "r2", "siteId": id("site"), "status": "active", "uHeight": 42.0, "width": 19.0,
+		}),
+		"netbox_rack_reservation": described("dcim/rack-reservations", map[string]interface{}{
+			"rackId": id("rack"), "units": []interface{}{1.0, 2.0}, "userId": id("user"), "description": "maintenance",
+		}),
+		"netbox_rack_role":    described("dcim/rack-roles", map[string]interface{}{"name": "network", "colorHex": "aaaaaa"}),
+		"netbox_region":       described("dcim/regions", map[string]interface{}{"name": "emea"}),
+		"netbox_rir":          described("ipam/rirs", map[string]interface{}{"name": "ripe"}),
+		"netbox_route_target": described("ipam/route-targets", map[string]interface{}{"name": "65000:1"}),
+		"netbox_service": changed("ipam/services", map[string]interface{}{
+			"name": "ssh", "protocol": "tcp", "ports": []interface{}{22.0}, "virtualMachineId": id("vm"),
+		}, map[string]interface{}{"ports": []interface{}{2222.0}}),
+		"netbox_site":         described("dcim/sites", map[string]interface{}{"name": "par1"}),
+		"netbox_site_group":   described("dcim/site-groups", map[string]interface{}{"name": "europe"}),
+		"netbox_tag":          described("extras/tags", map[string]interface{}{"name": "managed"}),
+		"netbox_tenant":       described("tenancy/tenants", map[string]interface{}{"name": "customer"}),
+		"netbox_tenant_group": described("tenancy/tenant-groups", map[string]interface{}{"name": "customers"}),
+		"netbox_token":        described("users/tokens", map[string]interface{}{"userId": id("user")}),
+		"netbox_user": changed("users/users", map[string]interface{}{
+			"username": "deployer", "password": "Sup3rSecret!",
+		}, map[string]interface{}{"active": false}),
+		"netbox_virtual_chassis": described("dcim/virtual-chassis", map[string]interface{}{"name": "stack1"}),
+		"netbox_virtual_disk": described("virtualization/virtual-disks", map[string]interface{}{
+			"name": "root", "sizeGb": 20.0, "virtualMachineId": id("vm"),
+		}),
+		"netbox_virtual_machine": described("virtualization/virtual-machines", map[string]interface{}{"name": "vm2", "siteId": id("site")}),
+		"netbox_vlan":            described("ipam/vlans", map[string]interface{}{"name": "users", "vid": 100.0}),
+		"netbox_vlan_group": described("ipam/vlan-groups", map[string]interface{}{
+			"name": "dc", "slug": "dc", "minVid": 1.0, "maxVid": 4094.0,
+		}),
+		"netbox_vpn_tunnel": described("vpn/tunnels", map[string]interface{}{
+			"name": "t2", "status": "active", "encapsulation": "gre", "tunnelGroupId": id("tunnelGroup"),
+		}),
+		"netbox_vpn_tunnel_group": described("vpn/tunnel-groups", map[string]interface{}{"name": "lan"}),
+		"netbox_vpn_tunnel_termination": {
+			endpoint: "vpn/tunnel-terminations",
+			inputs: map[string]interface{}{
+				"role": "peer", "tunnelId": id("tunnel"), "deviceInterfaceId": id("interface"),
+			},
+			update:     map[string]interface{}{"role": "hub"},
+			skipUpdate: "the upstream update asserts tunnel_id to int64 and panics",
+		},
+		"netbox_vrf":     described("ipam/vrfs", map[string]interface{}{"name": "blue"}),
+		"netbox_webhook": named("extras/webhooks", map[string]interface{}{"name": "notify", "payloadUrl": "https://example.com/hook"}),
+	}
+}
+
+// TestProviderSchema checks the schemas of the provider as Terraform does
+// before serving them, including those added or changed by the wrappers.
+func TestProviderSchema(t *testing.T) {
+	if err := netboxProvider().InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestResourceLifecycle runs create, read, update, import and delete for every
+// resource mapped in Provider() against the fake NetBox.
+func TestResourceLifecycle(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+
+	ids := fixtures(netbox)
+	cases := lifecycleCases(ids)
+	for tfName := range Provider().Resources {
+		if _, ok := cases[tfName]; !ok {
+			t.Errorf("no lifecycle case for %s", tfName)
+		}
+	}
+
+	for tfName, tc := range cases {
+		tfName, tc := tfName, tc
+		t.Run(tfName, func(t *testing.T) {
+			p := newTestProvider(t, netbox, nil)
+			urn := p.urn(tfName, "test")
+
+			inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(tc.inputs))
+			id, state := p.create(urn, inputs)
+			if id == "" {
+				t.Fatalf("create returned no ID")
+			}
+			p.assertStored(tc.endpoint, id, true)
+			assertContains(t, state, inputs)
+
+			readID, readState, _ := p.read(urn, id, state, inputs)
+			if readID != id {
+				t.Fatalf("read returned ID %q, want %q", readID, id)
+			}
+			assertContains(t, readState, inputs)
+			if diff := state.Diff(readState); diff != nil && diff.AnyChanges() {
+				t.Errorf("refresh after create changed the state: added %v, deleted %v, updated %v",
+					diff.Adds, diff.Deletes, diff.Updates)
+			}
+
+			desired := resource.NewPropertyMapFromMap(tc.inputs)
+			if tc.skipUpdate == "" {
+				changed := resource.NewPropertyMapFromMap(tc.inputs)
+				for k, v := range tc.update {
+					changed[resource.PropertyKey(k)] = resource.NewPropertyValue(v)
+				}
+				desired = changed
+				newInputs := p.check(urn, inputs, changed)
+				state = p.update(urn, id, readState, newInputs)
+				assertContains(t, state, resource.NewPropertyMapFromMap(tc.update))
+			} else {
+				t.Logf("skipping update: %s", tc.skipUpdate)
+			}
+
+			if tc.skipImport == "" {
+				importID := id
+				if tc.importID != nil {
+					importID = tc.importID(id)
+				}
+				gotID, imported, importedInputs := p.read(urn, importID, nil, nil)
+				if gotID != id {
+					t.Fatalf("import returned ID %q, want %q", gotID, id)
+				}
+				// Write-only and lookup-only inputs cannot be recovered on import, so
+				// only the properties the read returned are compared, besides those
+				// the import must recover.
+				for k := range desired {
+					if _, ok := imported[k]; !ok && !slices.Contains(tc.imported, string(k)) {
+						delete(desired, k)
+					}
+				}
+				assertContains(t, imported, desired)
+				if len(importedInputs) == 0 {
+					t.Errorf("import returned no inputs")
+				}
+			} else {
+				t.Logf("skipping import: %s", tc.skipImport)
+			}
+
+			p.delete(urn, id, state)
+			p.assertStored(tc.endpoint, id, false)
+		})
+	}
+}
+
+func (p *testProvider) assertStored(endpoint, id string, want bool) {
+	p.t.Helper()
+	if endpoint == "" {
+		return
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		p.t.Fatalf("unexpected ID %q: %v", id, err)
+	}
+	if _, ok := p.netbox.Get(endpoint, n); ok != want {
+		p.t.Fatalf("object %s/%s stored = %v, want %v", endpoint, id, ok, want)
+	}
+}
+
+func assertContains(t *testing.T, state, want resource.PropertyMap) {
+	t.Helper()
+	for k, v := range plain(want) {
+		got, ok := state[k]
+		if !ok {
+			t.Errorf("property %q missing from %v", k, state)
+			continue
+		}
+		if !plainValue(got).DeepEquals(plainValue(v)) {
+			t.Errorf("property %q = %v, want %v", k, got, v)
+		}
+	}
+}
+
+// plain strips secrets and the bridge's __defaults bookkeeping so inputs can be
+// compared with outputs.
+func plain(props resource.PropertyMap) resource.PropertyMap {
+	res := resource.PropertyMap{}
+	for k, v := range props {
+		if k == "__defaults" {
+			continue
+		}
+		res[k] = plainValue(v)
+	}
+	return res
+}
+
+func plainValue(v resource.PropertyValue) resource.PropertyValue {
+	switch {
+	case v.IsSecret():
+		return plainValue(v.SecretValue().Element)
+	case v.IsObject():
+		return resource.NewObjectProperty(plain(v.ObjectValue()))
+	case v.IsArray():
+		items := make([]resource.PropertyValue, len(v.ArrayValue()))
+		for i, item := range v.ArrayValue() {
+			items[i] = plainValue(item)
+		}
+		return resource.NewArrayProperty(items)
+	}
+	return v
+}
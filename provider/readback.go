@@ -0,0 +1,90 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// unreadCreates lists the resources whose upstream create does not read the
+// object it created, so the attributes NetBox fills in are missing from the
+// state until a refresh reports them as changes.
+var unreadCreates = map[string]bool{
+	"netbox_device_interface": true,
+	"netbox_interface":        true,
+}
+
+// unreadTags lists the resources whose upstream read leaves their tags out, so
+// a refresh neither records them nor notices tags changed outside of Pulumi.
+var unreadTags = map[string]bool{
+	"netbox_contact": true,
+	"netbox_tag":     true,
+	"netbox_tenant":  true,
+	"netbox_vrf":     true,
+}
+
+// readBackResource completes the state the upstream operations of r leave
+// out, so that a refresh right after them finds no changes.
+func readBackResource(name string, r *schema.Resource) {
+	if unreadTags[name] {
+		endpoint := objectTypes[name].endpoint
+		tags := func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			return diag.FromErr(readTags(ctx, d, meta, endpoint))
+		}
+		r.CreateContext = readAfter(r.CreateContext, tags)
+		r.ReadContext = schema.ReadContextFunc(readAfter(schema.CreateContextFunc(r.ReadContext), tags))
+		r.UpdateContext = schema.UpdateContextFunc(readAfter(schema.CreateContextFunc(r.UpdateContext), tags))
+	}
+	if unreadCreates[name] && r.ReadContext != nil {
+		r.CreateContext = readAfter(r.CreateContext, schema.CreateContextFunc(r.ReadContext))
+	}
+}
+
+// readAfter returns op followed by read, which only runs if op succeeded and
+// the object still exists.
+func readAfter(op, read schema.CreateContextFunc) schema.CreateContextFunc {
+	if op == nil {
+		return nil
+	}
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		diags := op(ctx, d, meta)
+		if diags.HasError() || d.Id() == "" {
+			return diags
+		}
+		return append(diags, read(ctx, d, meta)...)
+	}
+}
+
+// readTags sets the tags of d to the names of the tags of its object.
+func readTags(ctx context.Context, d *schema.ResourceData, meta interface{}, endpoint string) error {
+	res, err := apiCall(ctx, meta, http.MethodGet, fmt.Sprintf("%s/%s/", endpoint, d.Id()), nil, nil)
+	if err != nil {
+		return err
+	}
+	obj, _ := res.(map[string]interface{})
+	tags, _ := obj["tags"].([]interface{})
+	names := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		if tag, ok := tag.(map[string]interface{}); ok {
+			names = append(names, tag["name"])
+		}
+	}
+	return d.Set("tags", names)
+}
@@ -0,0 +1,55 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+// TestReadTags checks that a refresh records the tags added outside of Pulumi
+// to a resource whose upstream read leaves them out.
+func TestReadTags(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := newTestProvider(t, netbox, nil)
+	urn := p.urn("netbox_vrf", "test")
+
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{"name": "blue"}))
+	id, state := p.create(urn, inputs)
+
+	req, err := http.NewRequest(http.MethodPatch, netbox.URL+"/api/ipam/vrfs/"+id+"/",
+		strings.NewReader(`{"tags":[{"name":"edge","slug":"edge"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	_, read, _ := p.read(urn, id, state, inputs)
+	tags := read["tags"]
+	if !tags.IsArray() || len(tags.ArrayValue()) != 1 || tags.ArrayValue()[0].StringValue() != "edge" {
+		t.Errorf("tags = %v, want [edge]", tags)
+	}
+}
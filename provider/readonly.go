@@ -0,0 +1,151 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// readOnlyResource makes r refuse to plan a create, update or replace, and to
+// delete, when the provider is read-only.
+func readOnlyResource(name string, r *schema.Resource) {
+	customize := r.CustomizeDiff
+	r.CustomizeDiff = func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		if cfg := configFrom(meta); cfg.ReadOnly {
+			if change := describeChange(ctx, name, r, d); change != "" {
+				return cfg.refused.refuse(ctx, change)
+			}
+		}
+		if customize == nil {
+			return nil
+		}
+		return customize(ctx, d, meta)
+	}
+
+	del := r.DeleteContext
+	r.DeleteContext = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		if cfg := configFrom(meta); cfg.ReadOnly {
+			return diag.FromErr(cfg.refused.refuse(ctx, fmt.Sprintf("delete %s %s", describeResource(ctx, name), d.Id())))
+		}
+		return del(ctx, d, meta)
+	}
+}
+
+// describeChange describes what the plan d does to its resource, or returns
+// an empty string when it changes nothing.
+func describeChange(ctx context.Context, name string, r *schema.Resource, d *schema.ResourceDiff) string {
+	verb, props := plannedChange(r, d)
+	switch verb {
+	case "":
+		return ""
+	case "create":
+		return "create " + describeResource(ctx, name)
+	}
+	return fmt.Sprintf("%s %s %s (changed: %s)", verb, describeResource(ctx, name), d.Id(), strings.Join(props, ", "))
+}
+
+// refusedChanges gathers the changes a read-only provider refused during a
+// deployment, so each refusal lists every change found so far.
+type refusedChanges struct {
+	mu      sync.Mutex
+	changes map[string]bool
+}
+
+// refuse records change, and returns the error refusing it.
+func (c *refusedChanges) refuse(ctx context.Context, change string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changes == nil {
+		c.changes = map[string]bool{}
+	}
+	c.changes[change] = true
+
+	others := make([]string, 0, len(c.changes))
+	for other := range c.changes {
+		if other != change {
+			others = append(others, other)
+		}
+	}
+	msg := "the provider is read-only: refusing to " + change
+	if len(others) == 0 {
+		return errors.New(msg)
+	}
+	sort.Strings(others)
+	return fmt.Errorf("%s\nalso refused in this deployment:\n  %s", msg, strings.Join(others, "\n  "))
+}
+
+// plannedChange returns what the plan d does to its resource: create,
+// update, replace or nothing, and the properties it changes.
+func plannedChange(r *schema.Resource, d *schema.ResourceDiff) (string, []string) {
+	if d.Id() == "" {
+		return "create", nil
+	}
+	changed := d.GetChangedKeysPrefix("")
+	if len(changed) == 0 {
+		return "", nil
+	}
+
+	// Report top-level properties only, not every nested key.
+	seen := map[string]bool{}
+	var props []string
+	verb := "update"
+	for _, k := range changed {
+		k, _, _ = strings.Cut(k, ".")
+		if !seen[k] {
+			seen[k] = true
+			props = append(props, pulumiName(r, k))
+		}
+		if s, ok := r.Schema[k]; ok && s.ForceNew {
+			verb = "replace"
+		}
+	}
+	sort.Strings(props)
+	return verb, props
+}
+
+// describeResource names the resource an operation runs for, by URN when it
+// is known.
+func describeResource(ctx context.Context, name string) string {
+	if op, ok := operationFrom(ctx); ok && op.URN != "" {
+		return string(op.URN)
+	}
+	return name
+}
+
+// readOnlyTransport refuses every API call that could change NetBox, as a last
+// line of defence behind the checks made during the plan.
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+func (t readOnlyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	switch {
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+		return t.next.RoundTrip(r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/graphql/"):
+		// The GraphQL API of NetBox only answers queries.
+		return t.next.RoundTrip(r)
+	}
+	return nil, fmt.Errorf("the provider is read-only: refusing %s %s", r.Method, r.URL.Path)
+}
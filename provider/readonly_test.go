@@ -0,0 +1,107 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestReadOnly(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	writable := newTestProvider(t, netbox, nil)
+	p := newTestProvider(t, netbox, resource.PropertyMap{"readOnly": resource.NewBoolProperty(true)})
+
+	urn := p.urn("netbox_site", "par1")
+	inputs := p.check(urn, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})
+	_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn: string(urn), Properties: p.marshal(inputs), Preview: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "read-only: refusing to create "+string(urn)) {
+		t.Errorf("create preview: got error %v", err)
+	}
+
+	id, _ := writable.create(urn, inputs)
+	_, state, inputs := p.read(urn, id, nil, nil)
+
+	diff := func(news resource.PropertyMap) error {
+		_, err := p.server.Diff(context.Background(), &pulumirpc.DiffRequest{
+			Id: id, Urn: string(urn), Olds: p.marshal(state), News: p.marshal(p.check(urn, inputs, news)),
+		})
+		return err
+	}
+	if err := diff(inputs); err != nil {
+		t.Errorf("diff without changes: %v", err)
+	}
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("changed")
+	changed["latitude"] = resource.NewNumberProperty(48.85)
+	err = diff(changed)
+	if err == nil || !strings.Contains(err.Error(), "refusing to update "+string(urn)+" "+id+" (changed: description, latitude)") {
+		t.Errorf("diff with changes: got error %v", err)
+	}
+
+	if err := p.tryDelete(urn, id, state); err == nil || !strings.Contains(err.Error(), "refusing to delete") {
+		t.Errorf("delete: got error %v", err)
+	}
+	objectID, _ := strconv.ParseInt(id, 10, 64)
+	if _, ok := netbox.Get("dcim/sites", objectID); !ok {
+		t.Errorf("read-only provider deleted the site")
+	}
+}
+
+func TestReadOnlyListsChanges(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	writable := newTestProvider(t, netbox, nil)
+	p := newTestProvider(t, netbox, resource.PropertyMap{"readOnly": resource.NewBoolProperty(true)})
+
+	site := p.urn("netbox_site", "par1")
+	inputs := p.check(site, nil, resource.PropertyMap{"name": resource.NewStringProperty("par1")})
+	id, _ := writable.create(site, inputs)
+	_, state, inputs := p.read(site, id, nil, nil)
+	changed := inputs.Copy()
+	changed["description"] = resource.NewStringProperty("changed")
+	_, err := p.server.Diff(context.Background(), &pulumirpc.DiffRequest{
+		Id: id, Urn: string(site), Olds: p.marshal(state), News: p.marshal(p.check(site, inputs, changed)),
+	})
+	if err == nil {
+		t.Fatal("diff with changes succeeded")
+	}
+
+	tenant := p.urn("netbox_tenant", "acme")
+	_, err = p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+		Urn: string(tenant), Preview: true,
+		Properties: p.marshal(p.check(tenant, nil, resource.PropertyMap{"name": resource.NewStringProperty("acme")})),
+	})
+	want := "refusing to create " + string(tenant) + "\nalso refused in this deployment:\n  update " + string(site) + " " + id + " (changed: description)"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("second refusal: got error %v, want %q", err, want)
+	}
+
+	err = p.tryDelete(site, id, state)
+	if err == nil || !strings.Contains(err.Error(), "refusing to delete "+string(site)+" "+id) ||
+		!strings.Contains(err.Error(), "  create "+string(tenant)) {
+		t.Errorf("delete: got error %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/cassette"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// nonFieldKeys are the keys NetBox reports errors not tied to a field under.
+var nonFieldKeys = map[string]bool{
+	"__all__":          true,
+	"detail":           true,
+	"non_field_errors": true,
+}
+
+// fieldAliases maps NetBox fields to the resource properties setting them,
+// where adding _id to the field does not give the property.
+var fieldAliases = map[string]string{
+	"address":     "ip_address",
+	"color":       "color_hex",
+	"face":        "rack_face",
+	"position":    "rack_position",
+	"primary_ip4": "ip_address_id",
+	"primary_ip6": "ip_address_id",
+}
+
+// rejectionKey holds the *rejection of an operation in a context.
+type rejectionKey struct{}
+
+// rejection holds the body of the last 400 response of an operation.
+type rejection struct {
+	mu   sync.Mutex
+	body interface{}
+}
+
+func (r *rejection) set(body interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.body = body
+}
+
+func (r *rejection) get() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body
+}
+
+// rejectionTransport records the validation errors NetBox answers requests
+// with, for the operation that made them.
+type rejectionTransport struct {
+	next http.RoundTripper
+}
+
+func (t rejectionTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(r)
+	rej, ok := r.Context().Value(rejectionKey{}).(*rejection)
+	if err != nil || !ok || res.StatusCode != http.StatusBadRequest {
+		return res, err
+	}
+	b, err := cassette.ReadBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var body interface{}
+	if json.Unmarshal(b, &body) == nil {
+		rej.set(body)
+	}
+	return res, nil
+}
+
+// rejectionResource reports the validation errors NetBox rejects a create or
+// update of r with against the properties they concern, instead of as the raw
+// response.
+func rejectionResource(r *schema.Resource) {
+	reported := func(f schema.CreateContextFunc) schema.CreateContextFunc {
+		if f == nil {
+			return nil
+		}
+		return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			rej := &rejection{}
+			ctx = context.WithValue(ctx, rejectionKey{}, rej)
+			diags := f(ctx, d, withContext(ctx, meta))
+			body := rej.get()
+			if !diags.HasError() || body == nil {
+				return diags
+			}
+			var kept diag.Diagnostics
+			for _, d := range diags {
+				if d.Severity != diag.Error {
+					kept = append(kept, d)
+				}
+			}
+			return append(kept, rejectionDiagnostics(r, body)...)
+		}
+	}
+	r.CreateContext = reported(r.CreateContext)
+	r.UpdateContext = schema.UpdateContextFunc(reported(schema.CreateContextFunc(r.UpdateContext)))
+}
+
+// rejectionDiagnostics turns a NetBox validation error body, such as
+// {"rack_position": ["U42 is already occupied"]}, into one diagnostic per
+// message. Messages about a field of r are attached to its property.
+func rejectionDiagnostics(r *schema.Resource, body interface{}) diag.Diagnostics {
+	fields, ok := body.(map[string]interface{})
+	if !ok {
+		return generalDiagnostics(errorMessages("", body))
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var diags, general diag.Diagnostics
+	for _, k := range keys {
+		messages := errorMessages("", fields[k])
+		if nonFieldKeys[k] {
+			general = append(general, generalDiagnostics(messages)...)
+			continue
+		}
+		attr, ok := fieldAttribute(r, k)
+		name := k
+		if ok {
+			name = pulumiName(r, attr)
+		}
+		for _, msg := range messages {
+			d := diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "NetBox rejected `" + name + "`",
+				Detail:   msg,
+			}
+			if ok {
+				d.AttributePath = cty.GetAttrPath(attr)
+			}
+			diags = append(diags, d)
+		}
+	}
+	return append(diags, general...)
+}
+
+func generalDiagnostics(messages []string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, msg := range messages {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "NetBox rejected the request",
+			Detail:   msg,
+		})
+	}
+	return diags
+}
+
+// fieldAttribute returns the property of r that sets the NetBox field.
+func fieldAttribute(r *schema.Resource, field string) (string, bool) {
+	for _, attr := range []string{field, field + "_id", fieldAliases[field]} {
+		if _, ok := r.Schema[attr]; ok && attr != "" {
+			return attr, true
+		}
+	}
+	return "", false
+}
+
+// errorMessages flattens the messages of an error value, prefixing those of
+// nested fields and list items with their path.
+func errorMessages(prefix string, v interface{}) []string {
+	label := func(msg string) string {
+		if prefix == "" {
+			return msg
+		}
+		return prefix + ": " + msg
+	}
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := v.(type) {
+	case string:
+		return []string{label(v)}
+	case []interface{}:
+		var messages []string
+		for i, item := range v {
+			if _, ok := item.(string); ok {
+				messages = append(messages, errorMessages(prefix, item)...)
+			} else {
+				messages = append(messages, errorMessages(fmt.Sprintf("%s[%d]", prefix, i), item)...)
+			}
+		}
+		return messages
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var messages []string
+		for _, k := range keys {
+			messages = append(messages, errorMessages(join(k), v[k])...)
+		}
+		return messages
+	case nil:
+		return nil
+	}
+	return []string{label(strings.TrimSpace(fmt.Sprint(v)))}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestRejectionDiagnostics(t *testing.T) {
+	site := netboxProvider().ResourcesMap["netbox_site"]
+	var body interface{}
+	err := json.Unmarshal([]byte(`{
+		"group": ["Related object not found using the provided ID."],
+		"latitude": ["Ensure that there are no more than 2 digits before the decimal point."],
+		"custom_fields": {"owner": ["This field is required."]},
+		"tags": [{}, {"name": ["Tag with this name already exists."]}],
+		"non_field_errors": ["The fields name, tenant must make a unique set."]
+	}`), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		summary, detail string
+		path            cty.Path
+	}
+	want := []result{
+		{"NetBox rejected `customFields`", "owner: This field is required.", cty.GetAttrPath("custom_fields")},
+		{"NetBox rejected `groupId`", "Related object not found using the provided ID.", cty.GetAttrPath("group_id")},
+		{"NetBox rejected `latitude`", "Ensure that there are no more than 2 digits before the decimal point.", cty.GetAttrPath("latitude")},
+		{"NetBox rejected `tags`", "[1].name: Tag with this name already exists.", cty.GetAttrPath("tags")},
+		{"NetBox rejected the request", "The fields name, tenant must make a unique set.", nil},
+	}
+	diags := rejectionDiagnostics(site, body)
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(diags), len(want), diags)
+	}
+	for i, d := range diags {
+		got := result{d.Summary, d.Detail, d.AttributePath}
+		if got.summary != want[i].summary || got.detail != want[i].detail || !got.path.Equals(want[i].path) {
+			t.Errorf("diagnostic %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestRejectionOnApply(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	tc := lifecycleCases(fixtures(netbox))["netbox_device"]
+	netbox.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/dcim/devices/" {
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"position": ["U42 is already occupied or does not have sufficient space to accommodate this device type: switch (1U)"], "__all__": ["A device with this name already exists."]}`))
+		return true
+	})
+	p := newTestProvider(t, netbox, nil)
+
+	urn := p.urn("netbox_device", "leaf2")
+	_, _, err := p.tryCreate(urn, p.check(urn, nil, resource.NewPropertyMapFromMap(tc.inputs)))
+	if err == nil {
+		t.Fatal("create succeeded, want it rejected")
+	}
+	for _, want := range []string{
+		"NetBox rejected `rackPosition`: U42 is already occupied",
+		"NetBox rejected the request: A device with this name already exists.",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "dcim_devices_create default") {
+		t.Errorf("error %q still holds the raw response", err)
+	}
+}
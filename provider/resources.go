@@ -17,7 +17,6 @@ package netbox
 import (
 	"unicode"
 
-	"github.com/e-breuninger/terraform-provider-netbox/netbox"
 	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
 	shim "github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfshim"
 	shimv2 "github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfshim/sdk-v2"
@@ -71,7 +70,7 @@ func netboxResource(mod string, res string) tokens.Type {
 // Provider returns additional overlaid schema and metadata associated with the provider..
 func Provider() tfbridge.ProviderInfo {
 	// Instantiate the Terraform provider
-	p := shimv2.NewProvider(netbox.Provider())
+	p := shimv2.NewProvider(netboxProvider())
 
 	// Create a Pulumi provider mapping
 	prov := tfbridge.ProviderInfo{
@@ -118,6 +117,56 @@ func Provider() tfbridge.ProviderInfo {
                     EnvVars: []string{"NETBOX_SERVER_URL"},
                 },
             },
+			"record_path": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_RECORD"},
+				},
+			},
+			"replay_path": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_REPLAY"},
+				},
+			},
+			"debug_http": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_DEBUG_HTTP"},
+				},
+			},
+			"debug_http_max_body_size": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_DEBUG_HTTP_MAX_BODY_SIZE"},
+				},
+			},
+			"changelog_message": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_CHANGELOG_MESSAGE"},
+				},
+			},
+			"read_only": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_READ_ONLY"},
+				},
+			},
+			"permission_check": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_PERMISSION_CHECK"},
+				},
+			},
+			"delete_behavior": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_DELETE_BEHAVIOR"},
+				},
+			},
+			"delete_status": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_DELETE_STATUS"},
+				},
+			},
+			"delete_tag": {
+				Default: &tfbridge.DefaultInfo{
+					EnvVars: []string{"NETBOX_DELETE_TAG"},
+				},
+			},
 
             // TODO: Add the rest: https://registry.terraform.io/providers/e-breuninger/netbox/latest/docs#schema
 		},
@@ -128,8 +177,24 @@ func Provider() tfbridge.ProviderInfo {
 			// are below - the single line form is the common case. The multi-line form is
 			// needed only if you wish to override types or other default options.
             "netbox_aggregate": {Tok: netboxResource(netboxMod, "Aggregate")},
-            "netbox_available_ip_address": {Tok: netboxResource(netboxMod, "AvailableIpAddress")},
-            "netbox_available_prefix": {Tok: netboxResource(netboxMod, "AvailablePrefix")},
+			"netbox_available_asn": {Tok: netboxResource(netboxMod, "AvailableAsn")},
+			"netbox_available_ip_address": {
+				Tok: netboxResource(netboxMod, "AvailableIpAddress"),
+				Fields: map[string]*tfbridge.SchemaInfo{
+					"allocation_count": {
+						Name: "count",
+					},
+				},
+			},
+			"netbox_available_prefix": {
+				Tok: netboxResource(netboxMod, "AvailablePrefix"),
+				Fields: map[string]*tfbridge.SchemaInfo{
+					"allocation_count": {
+						Name: "count",
+					},
+				},
+			},
+			"netbox_available_vlan":      {Tok: netboxResource(netboxMod, "AvailableVlan")},
             "netbox_circuit": {Tok: netboxResource(netboxMod, "Circuit")},
             "netbox_circuit_provider": {Tok: netboxResource(netboxMod, "CircuitProvider")},
             "netbox_circuit_termination": {Tok: netboxResource(netboxMod, "CircuitTermination")},
@@ -153,6 +218,7 @@ func Provider() tfbridge.ProviderInfo {
             "netbox_ip_range": {Tok: netboxResource(netboxMod, "IpRange")},
             "netbox_ipam_role": {Tok: netboxResource(netboxMod, "IpamRole")},
             "netbox_manufacturer": {Tok: netboxResource(netboxMod, "Manufacturer")},
+			"netbox_object":       {Tok: netboxResource(netboxMod, "NetboxObject")},
             "netbox_platform": {Tok: netboxResource(netboxMod, "Platform")},
             "netbox_prefix": {
                 Tok: netboxResource(netboxMod, "Prefix"),
@@ -212,7 +278,6 @@ func Provider() tfbridge.ProviderInfo {
 						"netbox_vpn_tunnel_group": {Tok: netboxResource(netboxMod, "VpnTunnelGroup")},    
 						"netbox_vpn_tunnel_termination": {Tok: netboxResource(netboxMod, "VpnTunnelTermination")},    
 						"netbox_webhook": {Tok: netboxResource(netboxMod, "Webhook")},    
-            
 		},
 
 		DataSources: map[string]*tfbridge.DataSourceInfo{
@@ -239,15 +304,34 @@ func Provider() tfbridge.ProviderInfo {
             "netbox_vrf": {Tok: netboxDataSource(netboxMod, "getVrf")},
 						"netbox_asn": {Tok: netboxDataSource(netboxMod, "getAsn")},     
 						"netbox_asns": {Tok: netboxDataSource(netboxMod, "getAsns")},     
+			"netbox_available_ips": {
+				Tok: netboxDataSource(netboxMod, "getAvailableIps"),
+				Fields: map[string]*tfbridge.SchemaInfo{
+					"result_count": {
+						Name: "count",
+					},
+				},
+			},
 						"netbox_available_prefix": {Tok: netboxDataSource(netboxMod, "getAvailablePrefix")},     
+			"netbox_available_prefixes": {
+				Tok: netboxDataSource(netboxMod, "getAvailablePrefixes"),
+				Fields: map[string]*tfbridge.SchemaInfo{
+					"result_count": {
+						Name: "count",
+					},
+				},
+			},
 						"netbox_contact": {Tok: netboxDataSource(netboxMod, "getContact")},     
 						"netbox_contact_group": {Tok: netboxDataSource(netboxMod, "getContactGroup")},     
 						"netbox_contact_role": {Tok: netboxDataSource(netboxMod, "getContactRole")},     
 						"netbox_device_interfaces": {Tok: netboxDataSource(netboxMod, "getDeviceInterfaces")},     
 						"netbox_devices": {Tok: netboxDataSource(netboxMod, "getDevices")},     
+			"netbox_graphql_query":      {Tok: netboxDataSource(netboxMod, "graphqlQuery")},
 						"netbox_ipam_role": {Tok: netboxDataSource(netboxMod, "getIpamRole")},     
 						"netbox_location": {Tok: netboxDataSource(netboxMod, "getLocation")},     
 						"netbox_locations": {Tok: netboxDataSource(netboxMod, "getLocations")},     
+			"netbox_objects":            {Tok: netboxDataSource(netboxMod, "getObjects")},
+			"netbox_prefix_utilization": {Tok: netboxDataSource(netboxMod, "getPrefixUtilization")},
 						"netbox_prefixes": {Tok: netboxDataSource(netboxMod, "getPrefixes")},     
 						"netbox_rack_role": {Tok: netboxDataSource(netboxMod, "getRackRole")},     
 						"netbox_racks": {Tok: netboxDataSource(netboxMod, "getRacks")},     
@@ -259,7 +343,10 @@ func Provider() tfbridge.ProviderInfo {
 						"netbox_vrfs": {Tok: netboxDataSource(netboxMod, "getVrfs")},     
 		},
 
-	
+		Golang: &tfbridge.GolangInfo{
+			ImportBasePath:                 "github.com/SpikeeLabs/pulumi-netbox/sdk/go/netbox",
+			GenerateResourceContainerTypes: true,
+		},
 		Python: &tfbridge.PythonInfo{
             PackageName: "spk_pulumi_netbox",
 			// List any Python dependencies and their version ranges
@@ -0,0 +1,119 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// operation describes the provider operation a NetBox API call is made for.
+// The bridge does not hand the URN down to the Terraform provider, so it is
+// captured here, at the RPC level, and carried in the context.
+type operation struct {
+	// Kind is the provider RPC, such as Create or Read.
+	Kind string
+	// URN is the resource the operation is for, if any.
+	URN resource.URN
+	// RequestID identifies the operation in NetBox and in traces.
+	RequestID string
+}
+
+type operationKey struct{}
+
+func operationFrom(ctx context.Context) (*operation, bool) {
+	op, ok := ctx.Value(operationKey{}).(*operation)
+	return op, ok
+}
+
+// server wraps the bridged resource provider so every operation carries an
+// operation in its context and is traced.
+type server struct {
+	pulumirpc.ResourceProviderServer
+}
+
+func newServer(s pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+	return server{ResourceProviderServer: s}
+}
+
+func (s server) begin(ctx context.Context, kind, urn string) (context.Context, func(error)) {
+	op := &operation{Kind: kind, RequestID: uuid.NewString()}
+	if u, err := resource.ParseURN(urn); err == nil {
+		op.URN = u
+	}
+	ctx, span := startSpan(context.WithValue(ctx, operationKey{}, op), op)
+	return ctx, func(err error) { endSpan(span, err) }
+}
+
+func (s server) CheckConfig(ctx context.Context, req *pulumirpc.CheckRequest) (res *pulumirpc.CheckResponse, err error) {
+	ctx, end := s.begin(ctx, "CheckConfig", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.CheckConfig(ctx, req)
+}
+
+func (s server) Configure(ctx context.Context, req *pulumirpc.ConfigureRequest) (res *pulumirpc.ConfigureResponse, err error) {
+	ctx, end := s.begin(ctx, "Configure", "")
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Configure(ctx, req)
+}
+
+func (s server) Invoke(ctx context.Context, req *pulumirpc.InvokeRequest) (res *pulumirpc.InvokeResponse, err error) {
+	ctx, end := s.begin(ctx, "Invoke", "")
+	defer func() { end(err) }()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("pulumi.function", req.GetTok()))
+	return s.ResourceProviderServer.Invoke(ctx, req)
+}
+
+func (s server) Check(ctx context.Context, req *pulumirpc.CheckRequest) (res *pulumirpc.CheckResponse, err error) {
+	ctx, end := s.begin(ctx, "Check", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Check(ctx, req)
+}
+
+func (s server) Diff(ctx context.Context, req *pulumirpc.DiffRequest) (res *pulumirpc.DiffResponse, err error) {
+	ctx, end := s.begin(ctx, "Diff", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Diff(ctx, req)
+}
+
+func (s server) Create(ctx context.Context, req *pulumirpc.CreateRequest) (res *pulumirpc.CreateResponse, err error) {
+	ctx, end := s.begin(ctx, "Create", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Create(ctx, req)
+}
+
+func (s server) Read(ctx context.Context, req *pulumirpc.ReadRequest) (res *pulumirpc.ReadResponse, err error) {
+	ctx, end := s.begin(ctx, "Read", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Read(ctx, req)
+}
+
+func (s server) Update(ctx context.Context, req *pulumirpc.UpdateRequest) (res *pulumirpc.UpdateResponse, err error) {
+	ctx, end := s.begin(ctx, "Update", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Update(ctx, req)
+}
+
+func (s server) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (res *emptypb.Empty, err error) {
+	ctx, end := s.begin(ctx, "Delete", req.GetUrn())
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Delete(ctx, req)
+}
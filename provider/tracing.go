@@ -0,0 +1,224 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/SpikeeLabs/pulumi-netbox/provider"
+
+// Span attributes set on provider operations and the API calls they make.
+const (
+	urnKey          = attribute.Key("pulumi.urn")
+	resourceTypeKey = attribute.Key("pulumi.resource.type")
+	endpointKey     = attribute.Key("netbox.endpoint")
+	requestIDKey    = attribute.Key("netbox.request_id")
+)
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// setupTracing installs the global tracer provider from the standard OTEL_*
+// environment variables. Tracing stays disabled unless OTEL_TRACES_EXPORTER or
+// an OTLP endpoint is set:
+//
+//   - OTEL_TRACES_EXPORTER=otlp exports to OTEL_EXPORTER_OTLP_ENDPOINT, using
+//     OTEL_EXPORTER_OTLP_PROTOCOL (http/protobuf by default, or grpc).
+//   - OTEL_TRACES_EXPORTER=file appends JSON spans to PULUMI_NETBOX_TRACE_FILE.
+//     Neither the exporter nor the variable is part of the OpenTelemetry
+//     specification, hence the provider prefix.
+//
+// The returned function flushes and stops the exporter.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return noop, nil
+	}
+
+	exporter, err := newSpanExporter(ctx)
+	if err != nil || exporter == nil {
+		return noop, err
+	}
+
+	res, err := sdkresource.Merge(
+		sdkresource.Default(),
+		sdkresource.NewSchemaless(semconv.ServiceName("pulumi-resource-netbox")),
+	)
+	if err != nil {
+		return noop, err
+	}
+	// Let OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default
+	// service name.
+	if env, err := sdkresource.New(ctx, sdkresource.WithFromEnv()); err == nil {
+		if merged, err := sdkresource.Merge(res, env); err == nil {
+			res = merged
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+func newSpanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	if name == "" && (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") {
+		name = "otlp"
+	}
+
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+		if protocol == "" {
+			protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+		}
+		switch protocol {
+		case "", "http/protobuf":
+			return otlptracehttp.New(ctx)
+		case "grpc":
+			return otlptracegrpc.New(ctx)
+		default:
+			return nil, fmt.Errorf("unsupported OTLP protocol %q", protocol)
+		}
+	case "file":
+		path := os.Getenv("PULUMI_NETBOX_TRACE_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("PULUMI_NETBOX_TRACE_FILE must be set to use the file trace exporter")
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return fileExporter{SpanExporter: exporter, file: f}, nil
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q", name)
+	}
+}
+
+// fileExporter closes the trace file when the exporter shuts down.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if cerr := e.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// startSpan starts the span for a provider operation.
+func startSpan(ctx context.Context, op *operation) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "netbox."+op.Kind,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(op.attributes()...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// attributes describes the operation on its span and on the spans of the API
+// calls it makes.
+func (op *operation) attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{requestIDKey.String(op.RequestID)}
+	if op.URN != "" {
+		attrs = append(attrs, urnKey.String(string(op.URN)), resourceTypeKey.String(string(op.URN.Type())))
+	}
+	return attrs
+}
+
+// tracingTransport records a span for every NetBox API call, as a child of
+// the operation that made it.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	endpoint := apiEndpoint(r.URL.Path)
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(r.Method),
+		semconv.ServerAddress(r.URL.Hostname()),
+		semconv.URLPath(r.URL.Path),
+		endpointKey.String(endpoint),
+	}
+	if op, ok := operationFrom(r.Context()); ok {
+		attrs = append(attrs, op.attributes()...)
+	}
+	ctx, span := tracer().Start(r.Context(), fmt.Sprintf("NetBox %s %s", r.Method, endpoint),
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	res, err := t.next.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= 400 {
+		span.SetStatus(codes.Error, res.Status)
+	}
+	return res, nil
+}
+
+var numericSegment = regexp.MustCompile(`/\d+(/|$)`)
+
+// apiEndpoint returns the API path with object IDs replaced by a placeholder,
+// so calls to the same endpoint share a span name.
+func apiEndpoint(path string) string {
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i+len("/api"):]
+	}
+	return numericSegment.ReplaceAllString(path, "/{id}$1")
+}
@@ -0,0 +1,124 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := newTestProvider(t, netbox, nil)
+
+	urn := p.urn("netbox_site", "site")
+	inputs := p.check(urn, nil, resource.NewPropertyMapFromMap(map[string]interface{}{
+		"name": "par1",
+		"slug": "par1",
+	}))
+	p.create(urn, inputs)
+
+	var create sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "netbox.Create" {
+			create = span
+		}
+	}
+	if create == nil {
+		t.Fatalf("no span for the create operation")
+	}
+	var calls []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Parent().SpanID() == create.SpanContext().SpanID() {
+			calls = append(calls, span)
+		}
+	}
+	assertAttribute(t, create, "pulumi.urn", string(urn))
+	assertAttribute(t, create, "pulumi.resource.type", "netbox:index/site:Site")
+
+	if len(calls) == 0 {
+		t.Fatalf("no spans for API calls")
+	}
+	post := calls[0]
+	if post.Name() != "NetBox POST /dcim/sites/" {
+		t.Errorf("first API call span = %q", post.Name())
+	}
+	assertAttribute(t, post, "pulumi.urn", string(urn))
+	assertAttribute(t, post, "netbox.endpoint", "/dcim/sites/")
+	assertAttribute(t, post, "http.response.status_code", "201")
+	for _, call := range calls[1:] {
+		if call.Name() != "NetBox GET /dcim/sites/{id}/" {
+			t.Errorf("unexpected API call span %q", call.Name())
+		}
+	}
+}
+
+func TestFileTraceExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	t.Setenv("OTEL_TRACES_EXPORTER", "file")
+	t.Setenv("PULUMI_NETBOX_TRACE_FILE", path)
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+
+	shutdown, err := setupTracing(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, end := server{}.begin(context.Background(), "Read", "urn:pulumi:test::netbox::netbox:index/site:Site::site")
+	end(nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported struct{ Name string }
+	if err := json.Unmarshal(b, &exported); err != nil {
+		t.Fatalf("trace file is not JSON: %v\n%s", err, b)
+	}
+	if exported.Name != "netbox.Read" {
+		t.Errorf("exported span %q, want netbox.Read", exported.Name)
+	}
+}
+
+func assertAttribute(t *testing.T, span sdktrace.ReadOnlySpan, key, want string) {
+	t.Helper()
+	for _, kv := range span.Attributes() {
+		if kv.Key == attribute.Key(key) {
+			if got := kv.Value.Emit(); got != want {
+				t.Errorf("%s: %s = %q, want %q", span.Name(), key, got, want)
+			}
+			return
+		}
+	}
+	t.Errorf("%s: no %s attribute", span.Name(), key)
+}
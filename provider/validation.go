@@ -0,0 +1,242 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+	// Embed the time zone database, so time zones are checked alike on every
+	// host.
+	_ "time/tzdata"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// The VLAN IDs NetBox accepts.
+const (
+	minVID = 1
+	maxVID = 4094
+)
+
+// propertyChecks are the checks of a single property each. They replace the
+// looser upstream validation of the property, and run when inputs are checked.
+var propertyChecks = map[string]map[string]schema.SchemaValidateFunc{
+	"netbox_aggregate":  {"prefix": isNetwork},
+	"netbox_prefix":     {"prefix": isNetwork},
+	"netbox_ip_address": {"ip_address": isCIDR},
+	"netbox_ip_range": {
+		"start_address": isCIDR,
+		"end_address":   isCIDR,
+	},
+	"netbox_vlan": {"vid": validation.IntBetween(minVID, maxVID)},
+	"netbox_site": {
+		"latitude":  validation.FloatBetween(-90, 90),
+		"longitude": validation.FloatBetween(-180, 180),
+		"timezone":  isTimeZone,
+	},
+	"netbox_token": {"allowed_ips": isCIDR},
+}
+
+// planChecks are the checks, of several properties or against NetBox, made
+// when changes are planned.
+var planChecks = map[string]schema.CustomizeDiffFunc{
+	"netbox_ip_range":   checkIPRange,
+	"netbox_vlan":       checkVLAN,
+	"netbox_vlan_group": checkVLANGroup,
+	"netbox_device":     checkRackPosition,
+}
+
+// validateResource adds the semantic checks of r to its inputs and plan, so
+// inputs NetBox would reject fail the preview rather than the update.
+func validateResource(name string, r *schema.Resource) {
+	for k, check := range propertyChecks[name] {
+		s := r.Schema[k]
+		if elem, ok := s.Elem.(*schema.Schema); ok {
+			s = elem
+		}
+		s.ValidateFunc = check
+	}
+
+	check, ok := planChecks[name]
+	if !ok {
+		return
+	}
+	customize := r.CustomizeDiff
+	r.CustomizeDiff = func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		if err := check(ctx, d, meta); err != nil {
+			return fmt.Errorf("invalid %s: %w", describeResource(ctx, name), err)
+		}
+		if customize == nil {
+			return nil
+		}
+		return customize(ctx, d, meta)
+	}
+}
+
+// isNetwork checks that a string is a CIDR network without host bits, which
+// NetBox would otherwise silently clear.
+func isNetwork(i interface{}, _ string) ([]string, []error) {
+	v, _ := i.(string)
+	p, err := netip.ParsePrefix(v)
+	if err != nil {
+		return nil, []error{fmt.Errorf("%q is not a CIDR network, such as 10.0.0.0/24", v)}
+	}
+	if p.Masked() != p {
+		return nil, []error{fmt.Errorf("%q has host bits set; the network is %s", v, p.Masked())}
+	}
+	return nil, nil
+}
+
+// isCIDR checks that a string is an IP address with a prefix length, such as
+// 10.0.0.1/24.
+func isCIDR(i interface{}, _ string) ([]string, []error) {
+	v, _ := i.(string)
+	if _, err := netip.ParsePrefix(v); err != nil {
+		return nil, []error{fmt.Errorf("%q is not an IP address with a prefix length, such as 10.0.0.1/24", v)}
+	}
+	return nil, nil
+}
+
+// isTimeZone checks that a string names an IANA time zone.
+func isTimeZone(i interface{}, _ string) ([]string, []error) {
+	v, _ := i.(string)
+	if _, err := time.LoadLocation(v); err != nil || v == "Local" {
+		return nil, []error{fmt.Errorf("%q is not an IANA time zone, such as Europe/Paris", v)}
+	}
+	return nil, nil
+}
+
+// knownValues returns the planned values of keys of d, and whether they are
+// all set and known.
+func knownValues(d *schema.ResourceDiff, keys ...string) ([]interface{}, bool) {
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		v, ok := d.GetOk(k)
+		if !ok || !d.NewValueKnown(k) {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+func checkIPRange(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	values, ok := knownValues(d, "start_address", "end_address")
+	if !ok {
+		return nil
+	}
+	start, err := netip.ParsePrefix(values[0].(string))
+	if err != nil {
+		return nil
+	}
+	end, err := netip.ParsePrefix(values[1].(string))
+	if err != nil {
+		return nil
+	}
+	switch {
+	case start.Addr().Is4() != end.Addr().Is4():
+		return fmt.Errorf("startAddress %s and endAddress %s are not of the same address family", start, end)
+	case start.Bits() != end.Bits():
+		return fmt.Errorf("startAddress %s and endAddress %s do not have the same prefix length", start, end)
+	case start.Addr().Compare(end.Addr()) > 0:
+		return fmt.Errorf("startAddress %s is after endAddress %s", start, end)
+	}
+	return nil
+}
+
+func checkVLANGroup(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	values, ok := knownValues(d, "min_vid", "max_vid")
+	if !ok {
+		return nil
+	}
+	if min, max := values[0].(int), values[1].(int); min > max {
+		return fmt.Errorf("minVid %d is greater than maxVid %d", min, max)
+	}
+	return nil
+}
+
+// checkVLAN checks that the VID of a VLAN in a group is within the range of
+// the group.
+func checkVLAN(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChanges("vid", "group_id") {
+		return nil
+	}
+	values, ok := knownValues(d, "vid", "group_id")
+	if !ok {
+		return nil
+	}
+	vid, groupID := values[0].(int), values[1].(int)
+	group, err := apiCall(ctx, meta, http.MethodGet, fmt.Sprintf("ipam/vlan-groups/%d/", groupID), nil, nil)
+	if err != nil {
+		// A missing group is reported by the create or update.
+		return nil
+	}
+	g, _ := group.(map[string]interface{})
+	min, _ := g["min_vid"].(float64)
+	max, _ := g["max_vid"].(float64)
+	if max > 0 && (float64(vid) < min || float64(vid) > max) {
+		return fmt.Errorf("vid %d is outside the range %d-%d of VLAN group %v", vid, int(min), int(max), g["name"])
+	}
+	return nil
+}
+
+// checkRackPosition checks that a device fits in its rack at its position.
+func checkRackPosition(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChanges("rack_position", "rack_id", "device_type_id") {
+		return nil
+	}
+	values, ok := knownValues(d, "rack_position", "rack_id")
+	if !ok {
+		return nil
+	}
+	position, rackID := values[0].(float64), values[1].(int)
+	rack, err := apiCall(ctx, meta, http.MethodGet, fmt.Sprintf("dcim/racks/%d/", rackID), nil, nil)
+	if err != nil {
+		return nil
+	}
+	r, _ := rack.(map[string]interface{})
+	units, _ := r["u_height"].(float64)
+	if units == 0 {
+		return nil
+	}
+	// Racks number their units from 1 unless they say otherwise.
+	first := 1.0
+	if f, ok := r["starting_unit"].(float64); ok {
+		first = f
+	}
+	if position < first {
+		return fmt.Errorf("rackPosition %v is below the first unit %v of rack %v", position, first, r["name"])
+	}
+
+	// The device occupies its own height from its position up.
+	height := 1.0
+	if values, ok := knownValues(d, "device_type_id"); ok {
+		if typ, err := readDeviceType(ctx, meta, int64(values[0].(int))); err == nil {
+			height = typ.height
+		}
+	}
+	top := position + height - 1
+	if height == 0 {
+		top = position
+	}
+	if last := first + units - 1; top > last {
+		return fmt.Errorf("a %vU device at rackPosition %v does not fit in rack %v, whose last unit is %v", height, position, r["name"], last)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/SpikeeLabs/pulumi-netbox/provider/pkg/fakenetbox"
+)
+
+func TestCheckValidation(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	p := newTestProvider(t, netbox, nil)
+
+	tests := []struct {
+		tfName string
+		inputs map[string]interface{}
+		// property and reason are those of the expected failure, if any.
+		property, reason string
+	}{
+		{"netbox_prefix", map[string]interface{}{"prefix": "10.0.0.0/24", "status": "active"}, "", ""},
+		{"netbox_prefix", map[string]interface{}{"prefix": "10.0.0.1/24", "status": "active"}, "prefix", "the network is 10.0.0.0/24"},
+		{"netbox_prefix", map[string]interface{}{"prefix": "10.0.0.0/33", "status": "active"}, "prefix", "is not a CIDR network"},
+		{"netbox_aggregate", map[string]interface{}{"prefix": "2001:db8::1/32", "rirId": 1.0}, "prefix", "the network is 2001:db8::/32"},
+		{"netbox_ip_address", map[string]interface{}{"ipAddress": "10.0.0.1", "status": "active"}, "ipAddress", "is not an IP address with a prefix length"},
+		{"netbox_ip_range", map[string]interface{}{"startAddress": "10.0.0.300/24", "endAddress": "10.0.0.20/24"}, "startAddress", "is not an IP address"},
+		{"netbox_vlan", map[string]interface{}{"name": "users", "vid": 4095.0}, "vid", "expected vid to be in the range (1 - 4094)"},
+		{"netbox_site", map[string]interface{}{"name": "par1", "latitude": 91.0}, "latitude", "expected latitude to be in the range (-90.000000 - 90.000000)"},
+		{"netbox_site", map[string]interface{}{"name": "par1", "longitude": -180.5}, "longitude", "expected longitude to be in the range"},
+		{"netbox_site", map[string]interface{}{"name": "par1", "timezone": "Europe/Paris"}, "", ""},
+		{"netbox_site", map[string]interface{}{"name": "par1", "timezone": "Europe/Lutece"}, "timezone", "is not an IANA time zone"},
+		{"netbox_token", map[string]interface{}{"userId": 1.0, "allowedIps": []interface{}{"10.0.0.0/8", "10.1.1.1"}}, "allowedIps", "\"10.1.1.1\" is not an IP address with a prefix length"},
+	}
+	for _, tt := range tests {
+		urn := p.urn(tt.tfName, "test")
+		resp, err := p.server.Check(context.Background(), &pulumirpc.CheckRequest{
+			Urn:  string(urn),
+			News: p.marshal(resource.NewPropertyMapFromMap(tt.inputs)),
+		})
+		if err != nil {
+			t.Fatalf("%s %v: %v", tt.tfName, tt.inputs, err)
+		}
+		failures := resp.GetFailures()
+		if tt.property == "" {
+			if len(failures) > 0 {
+				t.Errorf("%s %v: unexpected failures %v", tt.tfName, tt.inputs, failures)
+			}
+			continue
+		}
+		if len(failures) != 1 || !strings.Contains(failures[0].GetReason(), tt.reason) ||
+			!strings.Contains(failures[0].GetReason(), "'test."+tt.property) {
+			t.Errorf("%s %v: got failures %v, want %s: %s", tt.tfName, tt.inputs, failures, tt.property, tt.reason)
+		}
+	}
+}
+
+func TestPlanValidation(t *testing.T) {
+	netbox := fakenetbox.NewServer()
+	defer netbox.Close()
+	ids := fixtures(netbox)
+	group := netbox.Create("ipam/vlan-groups", map[string]interface{}{"name": "dc1", "slug": "dc1", "min_vid": 100, "max_vid": 199})
+	p := newTestProvider(t, netbox, nil)
+
+	device := func(position float64) map[string]interface{} {
+		return map[string]interface{}{
+			"name": "leaf2", "deviceTypeId": float64(ids["deviceType"]), "roleId": float64(ids["deviceRole"]),
+			"siteId": float64(ids["site"]), "rackId": float64(ids["rack"]), "rackPosition": position, "rackFace": "front",
+		}
+	}
+	tests := []struct {
+		tfName string
+		inputs map[string]interface{}
+		// err is part of the expected error, if any.
+		err string
+	}{
+		{"netbox_ip_range", map[string]interface{}{"startAddress": "10.0.0.10/24", "endAddress": "10.0.0.20/24"}, ""},
+		{"netbox_ip_range", map[string]interface{}{"startAddress": "10.0.0.30/24", "endAddress": "10.0.0.20/24"}, "startAddress 10.0.0.30/24 is after endAddress 10.0.0.20/24"},
+		{"netbox_ip_range", map[string]interface{}{"startAddress": "10.0.0.10/24", "endAddress": "2001:db8::1/64"}, "not of the same address family"},
+		{"netbox_vlan_group", map[string]interface{}{"name": "dc2", "slug": "dc2", "minVid": 300.0, "maxVid": 200.0}, "minVid 300 is greater than maxVid 200"},
+		{"netbox_vlan", map[string]interface{}{"name": "users", "vid": 150.0, "groupId": float64(group)}, ""},
+		{"netbox_vlan", map[string]interface{}{"name": "users", "vid": 250.0, "groupId": float64(group)}, "vid 250 is outside the range 100-199 of VLAN group dc1"},
+		{"netbox_device", device(42), ""},
+		{"netbox_device", device(43), "does not fit in rack r1, whose last unit is 42"},
+	}
+	for _, tt := range tests {
+		urn := p.urn(tt.tfName, "test")
+		_, err := p.server.Create(context.Background(), &pulumirpc.CreateRequest{
+			Urn:        string(urn),
+			Properties: p.marshal(p.check(urn, nil, resource.NewPropertyMapFromMap(tt.inputs))),
+			Preview:    true,
+		})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s %v: %v", tt.tfName, tt.inputs, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s %v: got error %v, want %q", tt.tfName, tt.inputs, err, tt.err)
+		}
+	}
+}
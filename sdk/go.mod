@@ -0,0 +1,5 @@
+module github.com/SpikeeLabs/pulumi-netbox/sdk
+
+go 1.22
+
+require github.com/pulumi/pulumi/sdk/v3 v3.108.1